@@ -2,11 +2,37 @@ package agent
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
 )
 
+// Agent holds a dialog and the completion chain used to extend it.
+//
+// An Agent is safe for concurrent use. Steps are serialized: a call to Step
+// waits for any running step to finish. Messages may be added and read while
+// a step is running; the step doesn't see messages added after it started,
+// and its result is placed directly after the messages it did see.
+//
+// Messages themselves are not synchronized, so a message shouldn't be
+// modified once it has been added to an agent that is in use by several
+// goroutines.
 type Agent struct {
 	completionFunc CompletionFunc
-	messages       []*Message
+
+	stepMu sync.Mutex
+
+	mu          sync.Mutex
+	messages    []*Message
+	checkpoints map[string][]*Message
+
+	tree   *tree
+	branch string
+
+	snapshotters []namedSnapshotter
+	autoSave     *autoSave
+	observers    []Observer
 }
 
 type Option func(a *Agent)
@@ -19,9 +45,11 @@ func WithMiddleware(m MiddlewareFunc) Option {
 
 func New(c CompletionFunc, opts ...Option) *Agent {
 	a := &Agent{
-		completionFunc: c,
+		completionFunc: observeProvider(c),
 		messages:       make([]*Message, 0),
+		checkpoints:    make(map[string][]*Message),
 	}
+	a.tree = newTree(a)
 
 	for _, o := range opts {
 		o(a)
@@ -35,10 +63,17 @@ func New(c CompletionFunc, opts ...Option) *Agent {
 // The new agent will have the same capabilities and history as the previous
 // agent, but any changes will not be propogated to the original.
 func NewFromAgent(a *Agent) *Agent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	na := &Agent{
 		completionFunc: a.completionFunc,
 		messages:       make([]*Message, 0, len(a.messages)),
+		checkpoints:    make(map[string][]*Message),
+		snapshotters:   slices.Clone(a.snapshotters),
+		observers:      slices.Clone(a.observers),
 	}
+	na.tree = newTree(na)
 
 	for _, m := range a.messages {
 		na.messages = append(na.messages, NewMessageFromMessage(m))
@@ -48,27 +83,64 @@ func NewFromAgent(a *Agent) *Agent {
 }
 
 func (a *Agent) Add(role Role, content string) *Agent {
-	msg := newMessage()
-	msg.Role = role
-	msg.content = content
-
-	a.messages = append(a.messages, msg)
-	return a
+	return a.AddMessage(NewContentMessage(role, content))
 }
 
 func (a *Agent) AddMessage(m *Message) *Agent {
+	a.mu.Lock()
 	a.messages = append(a.messages, m)
+	a.mu.Unlock()
+
+	a.emit(context.Background(), Event{Type: EventMessageAdded, Message: m})
 	return a
 }
 
 func (a *Agent) Messages() []*Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	msgs := make([]*Message, len(a.messages))
 	copy(msgs, a.messages)
 	return msgs
 }
 
 func (a *Agent) Step(ctx context.Context) (*Message, error) {
-	nextMsg, err := a.completionFunc(ctx, a.messages, nil)
+	// Events emitted by middleware during the step go to this agent's
+	// observers, even when it runs inside another agent's step.
+	ctx = context.WithValue(ctx, observersKey{}, a.observers)
+
+	a.emit(ctx, Event{Type: EventStepStarted})
+
+	// The step's own events are emitted once it's done, so observers can
+	// edit the history or start the next step.
+	a.stepMu.Lock()
+	start := time.Now()
+	nextMsg, err := a.step(ctx)
+	duration := time.Since(start)
+	a.stepMu.Unlock()
+
+	if nextMsg != nil {
+		a.emit(ctx, Event{Type: EventMessageAdded, Message: nextMsg})
+	}
+
+	a.emit(ctx, Event{
+		Type:     EventStepFinished,
+		Duration: duration,
+		Message:  nextMsg,
+		Err:      err,
+	})
+
+	return nextMsg, err
+}
+
+// step runs the completion chain and adds its result. The message is
+// returned whenever it was added, even along with an error.
+func (a *Agent) step(ctx context.Context) (*Message, error) {
+	// The completion chain gets its own copy so messages can be added while
+	// it runs.
+	msgs := a.Messages()
+
+	nextMsg, err := a.completionFunc(ctx, msgs, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -76,11 +148,37 @@ func (a *Agent) Step(ctx context.Context) (*Message, error) {
 	// An empty step is oke. This would be possible if there is some
 	// internal state, like a sub-assistant, that hasn't yet resulted in a
 	// message.
-	if nextMsg == nil {
-		return nil, nil
+	if nextMsg != nil {
+		a.insert(len(msgs), nextMsg)
 	}
 
-	a.messages = append(a.messages, nextMsg)
+	if a.autoSave != nil {
+		s, err := a.snapshotState(ctx)
+		if err == nil {
+			err = a.autoSave.store.Save(ctx, a.autoSave.key, s)
+		}
+		if err != nil {
+			return nextMsg, fmt.Errorf("error saving agent: %w", err)
+		}
+	}
 
 	return nextMsg, nil
 }
+
+// insert places m at index i, after the messages a step saw.
+func (a *Agent) insert(i int, m *Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.messages) == i {
+		a.messages = append(a.messages, m)
+		return
+	}
+
+	// Build a new slice rather than shifting in place, which could
+	// overwrite messages shared with a checkpoint or fork.
+	updated := make([]*Message, 0, len(a.messages)+1)
+	updated = append(updated, a.messages[:i]...)
+	updated = append(updated, m)
+	a.messages = append(updated, a.messages[i:]...)
+}
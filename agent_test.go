@@ -2,7 +2,10 @@ package agent
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,3 +34,74 @@ func TestStep(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "why hello there", content)
 }
+
+func TestStepConcurrentAdd(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var seen int
+	mockFn := func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		seen = len(msgs)
+		started <- struct{}{}
+		<-release
+		return NewContentMessage(RoleAssistant, "reply"), nil
+	}
+
+	a := New(mockFn)
+	a.Add(RoleUser, "first")
+
+	done := make(chan error)
+	go func() {
+		_, err := a.Step(context.Background())
+		done <- err
+	}()
+
+	<-started
+	a.Add(RoleUser, "second")
+	assert.Len(t, a.Messages(), 2)
+	close(release)
+	require.NoError(t, <-done)
+
+	// The reply follows the messages the step saw.
+	assert.Equal(t, 1, seen)
+	var contents []string
+	for _, m := range a.Messages() {
+		c, _ := m.Content(context.Background())
+		contents = append(contents, c)
+	}
+	assert.Equal(t, []string{"first", "reply", "second"}, contents)
+}
+
+func TestStepSerialized(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	mockFn := func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		time.Sleep(time.Millisecond)
+		return NewContentMessage(RoleAssistant, "reply"), nil
+	}
+
+	a := New(mockFn)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := a.Step(context.Background())
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			a.Add(RoleUser, "hello")
+			_ = NewFromAgent(a)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxRunning.Load())
+	assert.Len(t, a.Messages(), 20)
+}
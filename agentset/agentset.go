@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/rhettg/agent"
 	"github.com/rhettg/agent/tools"
@@ -50,7 +51,10 @@ const (
 	stateWaiting
 )
 
+// AgentSet lets an agent hand the conversation over to other agents. It is
+// safe for concurrent use, though a set is meant to serve a single agent.
 type AgentSet struct {
+	mu             sync.Mutex
 	state          state
 	name           string
 	welcomeMsg     string
@@ -60,20 +64,20 @@ type AgentSet struct {
 }
 
 func (a *AgentSet) Add(name string, f startFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.agentFns[name] = f
 }
 
 func (a *AgentSet) Idle() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	return a.state == stateIdle
 }
 
 func (a *AgentSet) Start(ctx context.Context, arguments string) (string, error) {
-	if a.state != stateIdle {
-		return "Agent is already running", nil
-	}
-
-	a.state = stateStarted
-
 	args := struct {
 		Agent string `json:"agent"`
 	}{}
@@ -83,11 +87,19 @@ func (a *AgentSet) Start(ctx context.Context, arguments string) (string, error)
 		return "", err
 	}
 
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.state != stateIdle {
+		return "Agent is already running", nil
+	}
+
 	fn, ok := a.agentFns[args.Agent]
 	if !ok {
 		return fmt.Sprintf("Agent %s not found", args.Agent), nil
 	}
 
+	a.state = stateStarted
 	a.name = args.Agent
 
 	a.agentAssistant, a.welcomeMsg = fn()
@@ -95,58 +107,82 @@ func (a *AgentSet) Start(ctx context.Context, arguments string) (string, error)
 	return fmt.Sprintf("%s has entered the chat", a.name), nil
 }
 
-func (a *AgentSet) CompletionFunc(nextStep agent.CompletionFunc) agent.CompletionFunc {
-	return func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
-		slog.Debug("AgentSet.CompletionFunc", "agent", a.name, "agent_state", a.state)
-		switch a.state {
-		case stateStarted:
-			// This is one of the rare cases where a Step doesn't result in an
-			// Completion call.  It's really just a way of working around the
-			// API where the only way to add a message to the conversation is via
-			// a call to Step.
-			msg := agent.NewContentMessage(agent.RoleUser, a.welcomeMsg)
-			a.state = stateWaiting
-			slog.Debug("agent set state change", "agent", a.name, "state", a.state)
-			return msg, nil
-		case stateWaiting:
-			lastMsg := msgs[len(msgs)-1]
-			if lastMsg.Role == agent.RoleAssistant {
-				if !lastMsg.HasToolCalls() {
-					content, err := lastMsg.Content(ctx)
-					if err != nil {
-						return nil, err
-					}
-
-					a.agentAssistant.Add(agent.RoleUser, content)
-					a.state = stateRunning
-					slog.Debug("agent set state change", "agent", a.name, "state", a.state)
-				} else if toolCall := lastMsg.GetFirstToolCall(); toolCall != nil && toolCall.Name != "agent_stop" {
-					// When an agent is running we only allow the caller to call stop.
-					// We need this special case handling because the often the
-					// calling agent becomes confused *really* wanting to call
-					// functions.
-					msg := agent.NewContentMessage(agent.RoleUser, "I'm sorry, but I don't respond to functions like that. Just ask me directly.")
-					return msg, nil
+// transition handles state changes prompted by the latest message. It
+// returns a message if that settles the step, along with the resulting state
+// and running agent.
+func (a *AgentSet) transition(ctx context.Context, msgs []*agent.Message) (*agent.Message, state, *agent.Agent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	slog.Debug("AgentSet.CompletionFunc", "agent", a.name, "agent_state", a.state)
+	switch a.state {
+	case stateStarted:
+		// This is one of the rare cases where a Step doesn't result in an
+		// Completion call.  It's really just a way of working around the
+		// API where the only way to add a message to the conversation is via
+		// a call to Step.
+		msg := agent.NewContentMessage(agent.RoleUser, a.welcomeMsg)
+		a.state = stateWaiting
+		slog.Debug("agent set state change", "agent", a.name, "state", a.state)
+		return msg, a.state, a.agentAssistant, nil
+	case stateWaiting:
+		lastMsg := msgs[len(msgs)-1]
+		if lastMsg.Role == agent.RoleAssistant {
+			if !lastMsg.HasToolCalls() {
+				content, err := lastMsg.Content(ctx)
+				if err != nil {
+					return nil, a.state, a.agentAssistant, err
 				}
+
+				a.agentAssistant.Add(agent.RoleUser, content)
+				a.state = stateRunning
+				slog.Debug("agent set state change", "agent", a.name, "state", a.state)
+			} else if toolCall := lastMsg.GetFirstToolCall(); toolCall != nil && toolCall.Name != "agent_stop" {
+				// When an agent is running we only allow the caller to call stop.
+				// We need this special case handling because the often the
+				// calling agent becomes confused *really* wanting to call
+				// functions.
+				msg := agent.NewContentMessage(agent.RoleUser, "I'm sorry, but I don't respond to functions like that. Just ask me directly.")
+				return msg, a.state, a.agentAssistant, nil
 			}
-			// fall-through
-		case stateRunning, stateIdle:
-			// fall-through
+		}
+		// fall-through
+	case stateRunning, stateIdle:
+		// fall-through
+	}
+
+	return nil, a.state, a.agentAssistant, nil
+}
+
+func (a *AgentSet) CompletionFunc(nextStep agent.CompletionFunc) agent.CompletionFunc {
+	return func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		msg, st, assistant, err := a.transition(ctx, msgs)
+		if err != nil || msg != nil {
+			return msg, err
 		}
 
-		// Phase two, now that we may have handled some state changes.
+		// Phase two, now that we may have handled some state changes. The
+		// lock isn't held here: the Start and Stop tools run within the next
+		// step.
 
-		switch a.state {
+		switch st {
 		case stateRunning:
-			m, err := a.agentAssistant.Step(ctx)
+			m, err := assistant.Step(ctx)
 			if err != nil {
 				return nil, fmt.Errorf("error running agent: %w", err)
 			}
 
-			if m.Role == agent.RoleAssistant && !m.HasToolCalls() {
+			if m != nil && m.Role == agent.RoleAssistant && !m.HasToolCalls() {
 				rContent, _ := m.Content(ctx)
-				a.state = stateWaiting
-				slog.Debug("agent set state change", "agent", a.name, "state", a.state)
+
+				a.mu.Lock()
+				// The agent may have been stopped in the meantime.
+				if a.agentAssistant == assistant {
+					a.state = stateWaiting
+					slog.Debug("agent set state change", "agent", a.name, "state", a.state)
+				}
+				a.mu.Unlock()
+
 				return agent.NewContentMessage(agent.RoleUser, rContent), nil
 			}
 			return nil, nil
@@ -159,15 +195,19 @@ func (a *AgentSet) CompletionFunc(nextStep agent.CompletionFunc) agent.Completio
 }
 
 func (a *AgentSet) Stop(ctx context.Context, arguments string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	if a.name == "" {
 		return "No agent is currently running", nil
 	}
 
+	name := a.name
 	a.name = ""
 	a.agentAssistant = nil
 	a.state = stateIdle
 
-	return fmt.Sprintf("%s has left the chat", a.name), nil
+	return fmt.Sprintf("%s has left the chat", name), nil
 }
 
 func (a *AgentSet) Tools() *tools.Tools {
@@ -185,6 +225,9 @@ func New() *AgentSet {
 }
 
 func NewFromAgentSet(as *AgentSet) *AgentSet {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
 	nas := New()
 	for name, fn := range as.agentFns {
 		nas.Add(name, fn)
@@ -193,6 +236,11 @@ func NewFromAgentSet(as *AgentSet) *AgentSet {
 	return nas
 }
 
+// WithAgentSet adds the agent set to an agent, including its state in the
+// agent's snapshots.
 func WithAgentSet(s *AgentSet) agent.Option {
-	return agent.WithMiddleware(s.CompletionFunc)
+	return func(a *agent.Agent) {
+		agent.WithMiddleware(s.CompletionFunc)(a)
+		agent.WithSnapshotter("agentset", s)(a)
+	}
 }
@@ -0,0 +1,122 @@
+package agentset
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentSetConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	helper := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "helper reply"), nil
+	}
+	main := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "main reply"), nil
+	}
+
+	s := New()
+	s.Add("helper", func() (*agent.Agent, string) {
+		return agent.New(helper), "Hi, I'm the helper"
+	})
+
+	a := agent.New(main, WithAgentSet(s))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				s.Idle()
+			}
+		}
+	}()
+
+	result, err := s.Start(ctx, `{"agent": "helper"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "helper has entered the chat", result)
+
+	m, err := a.Step(ctx)
+	require.NoError(t, err)
+	c, _ := m.Content(ctx)
+	assert.Equal(t, "Hi, I'm the helper", c)
+
+	// The main agent answers, which is passed to the helper.
+	a.AddMessage(agent.NewContentMessage(agent.RoleAssistant, "hello helper"))
+	m, err = a.Step(ctx)
+	require.NoError(t, err)
+	c, _ = m.Content(ctx)
+	assert.Equal(t, "helper reply", c)
+
+	result, err = s.Stop(ctx, "{}")
+	require.NoError(t, err)
+	assert.Equal(t, "helper has left the chat", result)
+	assert.True(t, s.Idle())
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestAgentSetSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	helper := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "helper reply"), nil
+	}
+	main := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "main reply"), nil
+	}
+
+	newAgent := func() (*agent.Agent, *AgentSet) {
+		s := New()
+		s.Add("helper", func() (*agent.Agent, string) {
+			return agent.New(helper), "Hi, I'm the helper"
+		})
+		return agent.New(main, WithAgentSet(s)), s
+	}
+
+	a, s := newAgent()
+	_, err := s.Start(ctx, `{"agent": "helper"}`)
+	require.NoError(t, err)
+	_, err = a.Step(ctx)
+	require.NoError(t, err)
+	a.AddMessage(agent.NewContentMessage(agent.RoleAssistant, "hello helper"))
+	_, err = a.Step(ctx)
+	require.NoError(t, err)
+
+	store := agent.NewMemoryStore()
+	require.NoError(t, a.Save(ctx, store, "session"))
+
+	// Resume in a fresh agent, as after a restart.
+	ra, rs := newAgent()
+	require.NoError(t, ra.Load(ctx, store, "session"))
+
+	assert.False(t, rs.Idle())
+	assert.Len(t, ra.Messages(), 3)
+
+	rs.mu.Lock()
+	assistant := rs.agentAssistant
+	rs.mu.Unlock()
+	require.NotNil(t, assistant)
+
+	var contents []string
+	for _, m := range assistant.Messages() {
+		c, _ := m.Content(ctx)
+		contents = append(contents, c)
+	}
+	assert.Equal(t, []string{"hello helper", "helper reply"}, contents)
+
+	result, err := rs.Stop(ctx, "{}")
+	require.NoError(t, err)
+	assert.Equal(t, "helper has left the chat", result)
+}
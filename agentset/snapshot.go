@@ -0,0 +1,74 @@
+package agentset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rhettg/agent"
+)
+
+type setSnapshot struct {
+	State      state           `json:"state"`
+	Name       string          `json:"name,omitempty"`
+	WelcomeMsg string          `json:"welcome_msg,omitempty"`
+	Agent      *agent.Snapshot `json:"agent,omitempty"`
+}
+
+// Snapshot saves the state of the set, including the running agent.
+func (a *AgentSet) Snapshot(ctx context.Context) (json.RawMessage, error) {
+	a.mu.Lock()
+	ss := setSnapshot{
+		State:      a.state,
+		Name:       a.name,
+		WelcomeMsg: a.welcomeMsg,
+	}
+	assistant := a.agentAssistant
+	a.mu.Unlock()
+
+	if assistant != nil {
+		s, err := assistant.Snapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ss.Agent = s
+	}
+
+	return json.Marshal(ss)
+}
+
+// Restore resumes a saved state. The running agent is recreated from the
+// function it was added with and then restored.
+func (a *AgentSet) Restore(ctx context.Context, data json.RawMessage) error {
+	var ss setSnapshot
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	fn, ok := a.agentFns[ss.Name]
+	a.mu.Unlock()
+
+	var assistant *agent.Agent
+	if ss.Name != "" {
+		if !ok {
+			return fmt.Errorf("agent %s not found", ss.Name)
+		}
+
+		assistant, _ = fn()
+		if ss.Agent != nil {
+			if err := assistant.Restore(ctx, ss.Agent); err != nil {
+				return err
+			}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.state = ss.State
+	a.name = ss.Name
+	a.welcomeMsg = ss.WelcomeMsg
+	a.agentAssistant = assistant
+	return nil
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MainBranch is the name of the branch an agent created by New starts on.
+const MainBranch = "main"
+
+// ErrCheckpointNotFound is returned when rewinding to a checkpoint that
+// doesn't exist.
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// ErrBranchExists is returned when forking with a name already used in the
+// conversation tree.
+var ErrBranchExists = errors.New("branch already exists")
+
+// Branch is one line of conversation in the tree started by an agent.
+type Branch struct {
+	Name string
+
+	// Parent is the branch this one was forked from, empty for the main
+	// branch.
+	Parent string
+
+	// ForkedAt is the number of messages shared with the parent.
+	ForkedAt int
+
+	Agent *Agent
+}
+
+// tree tracks the branches forked from a common agent.
+type tree struct {
+	mu       sync.Mutex
+	branches []Branch
+}
+
+func newTree(a *Agent) *tree {
+	a.branch = MainBranch
+	return &tree{branches: []Branch{{Name: MainBranch, Agent: a}}}
+}
+
+func (t *tree) add(b Branch) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, existing := range t.branches {
+		if existing.Name == b.Name {
+			return fmt.Errorf("%w: %s", ErrBranchExists, b.Name)
+		}
+	}
+
+	t.branches = append(t.branches, b)
+	return nil
+}
+
+// snapshot returns the current messages, capped so that appending to either
+// the agent's history or the snapshot copies rather than overwriting the
+// other.
+func (a *Agent) snapshot() []*Message {
+	return a.messages[:len(a.messages):len(a.messages)]
+}
+
+// Branch returns the name of the agent's branch in its conversation tree.
+func (a *Agent) Branch() string {
+	return a.branch
+}
+
+// Branches lists every branch in the agent's conversation tree, in the order
+// they were created.
+func (a *Agent) Branches() []Branch {
+	a.tree.mu.Lock()
+	defer a.tree.mu.Unlock()
+
+	branches := make([]Branch, len(a.tree.branches))
+	copy(branches, a.tree.branches)
+	return branches
+}
+
+// Fork creates a new branch continuing from the agent's current messages.
+//
+// The fork shares the history with the original rather than copying it, so
+// forking is cheap. Messages added afterwards to either agent are only seen
+// by that agent. Since the messages themselves are shared, they shouldn't be
+// modified after forking. Use NewFromAgent for an independent copy.
+func (a *Agent) Fork(name string) (*Agent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	na := &Agent{
+		completionFunc: a.completionFunc,
+		messages:       a.snapshot(),
+		checkpoints:    make(map[string][]*Message, len(a.checkpoints)),
+		tree:           a.tree,
+		branch:         name,
+		snapshotters:   slices.Clone(a.snapshotters),
+		observers:      slices.Clone(a.observers),
+	}
+
+	for k, v := range a.checkpoints {
+		na.checkpoints[k] = v
+	}
+
+	err := a.tree.add(Branch{
+		Name:     name,
+		Parent:   a.branch,
+		ForkedAt: len(a.messages),
+		Agent:    na,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return na, nil
+}
+
+// Checkpoint records the current messages under name, replacing any earlier
+// checkpoint with that name.
+func (a *Agent) Checkpoint(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.checkpoints[name] = a.snapshot()
+}
+
+// Checkpoints returns the names of the agent's checkpoints, sorted.
+func (a *Agent) Checkpoints() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	names := make([]string, 0, len(a.checkpoints))
+	for name := range a.checkpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rewind restores the messages recorded by a checkpoint, discarding those
+// added since. Fork first to keep the current continuation. Rewind waits for
+// a running step to finish, and observers are told about the messages
+// removed.
+//
+// Only messages are restored. State kept by middleware registered with
+// WithSnapshotter, such as a Tools result cache, is left as it is; use
+// Snapshot and Restore to return to an earlier state as a whole.
+func (a *Agent) Rewind(name string) error {
+	a.stepMu.Lock()
+	a.mu.Lock()
+	msgs, ok := a.checkpoints[name]
+	prev := a.messages
+	if ok {
+		a.messages = msgs
+	}
+	a.mu.Unlock()
+	a.stepMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrCheckpointNotFound, name)
+	}
+
+	a.emitReplaced(context.Background(), prev, msgs)
+	return nil
+}
+
+// emitReplaced tells observers about the messages removed and added when the
+// whole history is swapped for another.
+func (a *Agent) emitReplaced(ctx context.Context, prev, msgs []*Message) {
+	kept := make(map[*Message]bool, len(msgs))
+	for _, m := range msgs {
+		kept[m] = true
+	}
+	existing := make(map[*Message]bool, len(prev))
+	for _, m := range prev {
+		existing[m] = true
+		if !kept[m] {
+			a.emit(ctx, Event{Type: EventMessageRemoved, Message: m})
+		}
+	}
+	for _, m := range msgs {
+		if !existing[m] {
+			a.emit(ctx, Event{Type: EventMessageAdded, Message: m})
+		}
+	}
+}
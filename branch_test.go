@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCompletion replies with the number of messages it was given.
+func countingCompletion(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+	return NewContentMessage(RoleAssistant, fmt.Sprintf("reply to %d", len(msgs))), nil
+}
+
+func contents(t *testing.T, a *Agent) []string {
+	var cs []string
+	for _, m := range a.Messages() {
+		c, err := m.Content(context.Background())
+		require.NoError(t, err)
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+func TestCheckpointRewind(t *testing.T) {
+	ctx := context.Background()
+	a := New(countingCompletion)
+	a.Add(RoleUser, "hello")
+	a.Checkpoint("start")
+
+	_, err := a.Step(ctx)
+	require.NoError(t, err)
+	a.Checkpoint("answered")
+	a.Add(RoleUser, "bad question")
+	_, err = a.Step(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Rewind("answered"))
+	assert.Equal(t, []string{"hello", "reply to 1"}, contents(t, a))
+
+	// Continuing after a rewind doesn't disturb other checkpoints.
+	a.Add(RoleUser, "better question")
+	require.NoError(t, a.Rewind("start"))
+	assert.Equal(t, []string{"hello"}, contents(t, a))
+	require.NoError(t, a.Rewind("answered"))
+	assert.Equal(t, []string{"hello", "reply to 1"}, contents(t, a))
+
+	assert.Equal(t, []string{"answered", "start"}, a.Checkpoints())
+	assert.ErrorIs(t, a.Rewind("missing"), ErrCheckpointNotFound)
+}
+
+func TestFork(t *testing.T) {
+	ctx := context.Background()
+	a := New(countingCompletion)
+	a.Add(RoleUser, "hello")
+	a.Checkpoint("start")
+
+	b, err := a.Fork("what-if")
+	require.NoError(t, err)
+
+	a.Add(RoleUser, "main path")
+	b.Add(RoleUser, "other path")
+	_, err = b.Step(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"hello", "main path"}, contents(t, a))
+	assert.Equal(t, []string{"hello", "other path", "reply to 2"}, contents(t, b))
+
+	// History is shared, not copied.
+	assert.Same(t, a.Messages()[0], b.Messages()[0])
+
+	// Forks inherit checkpoints but rewind independently.
+	require.NoError(t, b.Rewind("start"))
+	assert.Equal(t, []string{"hello"}, contents(t, b))
+	assert.Equal(t, []string{"hello", "main path"}, contents(t, a))
+
+	c, err := b.Fork("nested")
+	require.NoError(t, err)
+
+	_, err = a.Fork("what-if")
+	assert.ErrorIs(t, err, ErrBranchExists)
+
+	branches := c.Branches()
+	require.Len(t, branches, 3)
+	assert.Equal(t, Branch{Name: MainBranch, Agent: a}, branches[0])
+	assert.Equal(t, Branch{Name: "what-if", Parent: MainBranch, ForkedAt: 1, Agent: b}, branches[1])
+	assert.Equal(t, Branch{Name: "nested", Parent: "what-if", ForkedAt: 1, Agent: c}, branches[2])
+	assert.Equal(t, "nested", c.Branch())
+
+	// Copies start a tree of their own.
+	d := NewFromAgent(a)
+	assert.Equal(t, MainBranch, d.Branch())
+	assert.Len(t, d.Branches(), 1)
+}
+
+func TestForkObserversIndependent(t *testing.T) {
+	ctx := context.Background()
+
+	var oneEvents, twoEvents int
+	nop := func(ctx context.Context, e Event) {}
+	a := New(countingCompletion, WithObserver(nop), WithObserver(nop), WithObserver(nop))
+	a.Add(RoleUser, "hello")
+
+	f1, err := a.Fork("one")
+	require.NoError(t, err)
+	f2, err := a.Fork("two")
+	require.NoError(t, err)
+
+	WithObserver(func(ctx context.Context, e Event) { oneEvents++ })(f1)
+	WithObserver(func(ctx context.Context, e Event) { twoEvents++ })(f2)
+
+	_, err = f1.Step(ctx)
+	require.NoError(t, err)
+	_, err = a.Step(ctx)
+	require.NoError(t, err)
+
+	assert.NotZero(t, oneEvents)
+	assert.Zero(t, twoEvents)
+}
@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ConversationVersion is the version of the conversation document written by
+// ExportMessagesToJSON. Documents with a newer version are rejected.
+//
+// Version 2 added ordered content parts.
+const ConversationVersion = 2
+
+// Conversation is a versioned document holding a list of messages.
+type Conversation struct {
+	Version  int        `json:"version"`
+	Messages []*Message `json:"messages"`
+}
+
+type toolCallJSON struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type imageJSON struct {
+	Name     string `json:"name"`
+	Data     []byte `json:"data,omitempty"`
+	URL      string `json:"url,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type partJSON struct {
+	Type     PartType `json:"type"`
+	Text     string   `json:"text,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	MIMEType string   `json:"mime_type,omitempty"`
+	Format   string   `json:"format,omitempty"`
+	Data     []byte   `json:"data,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// messageJSON holds content either as content and images, which covers most
+// messages, or as an ordered list of parts.
+type messageJSON struct {
+	ID         string            `json:"id,omitempty"`
+	CreatedAt  string            `json:"created_at,omitempty"`
+	Role       Role              `json:"role"`
+	Content    string            `json:"content,omitempty"`
+	Parts      []partJSON        `json:"parts,omitempty"`
+	Reasoning  string            `json:"reasoning,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	ToolCalls  []toolCallJSON    `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	Images     []imageJSON       `json:"images,omitempty"`
+	Attrs      map[string]string `json:"attrs,omitempty"`
+	Provenance *provenanceRecord `json:"provenance,omitempty"`
+}
+
+func encodePart(p Part) partJSON {
+	pj := partJSON{Type: p.Type, Text: p.Text}
+	switch {
+	case p.Image != nil:
+		pj.Name, pj.Data, pj.MIMEType = p.Image.Name, p.Image.Data, p.Image.MIMEType
+		pj.URL, pj.Detail = p.Image.URL, p.Image.Detail
+	case p.File != nil:
+		pj.Name, pj.MIMEType, pj.Data = p.File.Name, p.File.MIMEType, p.File.Data
+	case p.Audio != nil:
+		pj.Format, pj.Data = p.Audio.Format, p.Audio.Data
+	}
+	return pj
+}
+
+func decodePart(pj partJSON) (Part, error) {
+	switch pj.Type {
+	case PartText:
+		return TextPart(pj.Text), nil
+	case PartImage:
+		return ImagePartFrom(Image{
+			Name:     pj.Name,
+			Data:     pj.Data,
+			URL:      pj.URL,
+			MIMEType: pj.MIMEType,
+			Detail:   pj.Detail,
+		}), nil
+	case PartFile:
+		return FilePart(pj.Name, pj.MIMEType, pj.Data), nil
+	case PartAudio:
+		return AudioPart(pj.Format, pj.Data), nil
+	default:
+		return Part{}, fmt.Errorf("unknown part type %q", pj.Type)
+	}
+}
+
+// MarshalJSON encodes the message including its content parts and
+// attributes. Dynamic content is resolved without a deadline; use
+// ExportMessagesToJSON to resolve it with a context.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	parts, err := resolveParts(context.Background(), m)
+	if err != nil {
+		return nil, err
+	}
+
+	mj := messageJSON{
+		ID:         m.id,
+		CreatedAt:  encodeTime(m.createdAt),
+		Provenance: encodeProvenance(m.provenance),
+		Role:       m.Role,
+		Reasoning:  m.reasoning,
+		Name:       m.Name,
+		ToolCallID: m.ToolCallID,
+	}
+
+	if content, images, ok := splitSimple(parts); ok {
+		mj.Content = content
+		for _, img := range images {
+			mj.Images = append(mj.Images, imageJSON{
+				Name:     img.Name,
+				Data:     img.Data,
+				URL:      img.URL,
+				MIMEType: img.MIMEType,
+				Detail:   img.Detail,
+			})
+		}
+	} else {
+		for _, p := range parts {
+			mj.Parts = append(mj.Parts, encodePart(p))
+		}
+	}
+
+	for _, tc := range m.ToolCalls {
+		mj.ToolCalls = append(mj.ToolCalls, toolCallJSON(tc))
+	}
+
+	if len(m.attrs) > 0 {
+		mj.Attrs = m.attrs
+	}
+
+	return json.Marshal(mj)
+}
+
+// UnmarshalJSON decodes a message produced by MarshalJSON. The message
+// content is always static.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var mj messageJSON
+	if err := json.Unmarshal(data, &mj); err != nil {
+		return err
+	}
+
+	if mj.Role == "" {
+		return errors.New("message has no role")
+	}
+
+	if len(mj.Parts) > 0 && (mj.Content != "" || len(mj.Images) > 0) {
+		return errors.New("message has both parts and content")
+	}
+
+	nm := NewContentMessage(mj.Role, mj.Content)
+	if err := nm.setIdentity(mj.ID, mj.CreatedAt, mj.Provenance); err != nil {
+		return err
+	}
+	nm.reasoning = mj.Reasoning
+	nm.Name = mj.Name
+	nm.ToolCallID = mj.ToolCallID
+
+	for _, tc := range mj.ToolCalls {
+		nm.ToolCalls = append(nm.ToolCalls, ToolCall(tc))
+	}
+
+	for _, img := range mj.Images {
+		nm.AddPart(ImagePartFrom(Image{
+			Name:     img.Name,
+			Data:     img.Data,
+			URL:      img.URL,
+			MIMEType: img.MIMEType,
+			Detail:   img.Detail,
+		}))
+	}
+
+	for i, pj := range mj.Parts {
+		p, err := decodePart(pj)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", i, err)
+		}
+		nm.AddPart(p)
+	}
+
+	for k, v := range mj.Attrs {
+		nm.attrs[k] = v
+	}
+
+	*m = *nm
+	return nil
+}
+
+// resolveParts returns the parts of the message with dynamic content and
+// lazily loaded images resolved.
+func resolveParts(ctx context.Context, m *Message) ([]Part, error) {
+	parts, err := m.Parts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting message content: %w", err)
+	}
+
+	for i, p := range parts {
+		if p.Image != nil && p.Image.Loader != nil {
+			img, err := p.Image.Resolve(ctx)
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = ImagePartFrom(img)
+		}
+	}
+
+	return parts, nil
+}
+
+// resolveContent returns a copy of the message with dynamic content and
+// lazily loaded images replaced by their current value.
+func resolveContent(ctx context.Context, m *Message) (*Message, error) {
+	lazy := m.contentFn != nil
+	for _, p := range m.parts {
+		if p.Image != nil && p.Image.Loader != nil {
+			lazy = true
+		}
+	}
+	if !lazy {
+		return m, nil
+	}
+
+	parts, err := resolveParts(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	nm := NewMessageFromMessage(m)
+	nm.contentFn = nil
+	nm.parts = parts
+	return nm, nil
+}
+
+// ExportMessagesToJSON encodes the messages as a conversation document.
+func ExportMessagesToJSON(ctx context.Context, messages []*Message) (string, error) {
+	doc := Conversation{
+		Version:  ConversationVersion,
+		Messages: make([]*Message, len(messages)),
+	}
+
+	for i, m := range messages {
+		rm, err := resolveContent(ctx, m)
+		if err != nil {
+			return "", err
+		}
+		doc.Messages[i] = rm
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling messages to JSON: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// ImportMessagesFromJSON decodes a conversation document produced by
+// ExportMessagesToJSON.
+func ImportMessagesFromJSON(jsonString string) ([]*Message, error) {
+	var doc Conversation
+	if err := json.Unmarshal([]byte(jsonString), &doc); err != nil {
+		return nil, fmt.Errorf("error unmarshaling JSON: %w", err)
+	}
+
+	if doc.Version < 1 || doc.Version > ConversationVersion {
+		return nil, fmt.Errorf("unsupported conversation version %d", doc.Version)
+	}
+
+	for i, m := range doc.Messages {
+		if m == nil {
+			return nil, fmt.Errorf("message %d is null", i)
+		}
+	}
+
+	return doc.Messages, nil
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConversation() []*Message {
+	user := NewImageMessage(RoleUser, "What is in this image?", "cat.png", []byte{0x89, 'P', 'N', 'G'})
+	user.Name = "alice"
+	user.Tag("agt:important")
+
+	assistant := NewContentMessage(RoleAssistant, "")
+	assistant.ToolCalls = []ToolCall{{ID: "call_1", Name: "classify", Arguments: `{"n": 1}`}}
+	assistant.SetAttr("model", "gpt")
+	assistant.SetReasoning("The user wants the image classified.")
+	assistant.SetProvenance(Provenance{
+		Provider:     "openai",
+		Model:        "gpt",
+		ResponseID:   "chatcmpl-1",
+		FinishReason: "tool_calls",
+		Latency:      1500 * time.Millisecond,
+		Usage:        Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	})
+
+	tool := NewContentMessage(RoleTool, "cat")
+	tool.ToolCallID = "call_1"
+
+	dynamic := NewDynamicMessage(RoleSystem, func(ctx context.Context) (string, error) {
+		return "dynamic content", nil
+	})
+
+	return []*Message{user, assistant, tool, dynamic}
+}
+
+func TestMessageJSON(t *testing.T) {
+	for _, m := range testConversation() {
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+
+		var got Message
+		require.NoError(t, json.Unmarshal(data, &got))
+
+		wantContent, _ := m.Content(context.Background())
+		gotContent, _ := got.Content(context.Background())
+		assert.Equal(t, wantContent, gotContent)
+		assert.Equal(t, m.ID(), got.ID())
+		assert.True(t, m.CreatedAt().Equal(got.CreatedAt()))
+		assert.Equal(t, m.Provenance(), got.Provenance())
+		assert.Equal(t, m.Role, got.Role)
+		assert.Equal(t, m.Reasoning(), got.Reasoning())
+		assert.Equal(t, m.Name, got.Name)
+		assert.Equal(t, m.ToolCalls, got.ToolCalls)
+		assert.Equal(t, m.ToolCallID, got.ToolCallID)
+		assert.Equal(t, m.Images(), got.Images())
+		assert.Equal(t, len(m.attrs), len(got.attrs))
+		for k, v := range m.attrs {
+			assert.Equal(t, v, got.GetAttr(k))
+		}
+
+		// Decoded messages are fully usable.
+		got.Tag("agt:seen")
+	}
+
+	var m Message
+	assert.Error(t, json.Unmarshal([]byte(`{"content": "no role"}`), &m))
+}
+
+func TestMessageJSONFormat(t *testing.T) {
+	msgs := testConversation()
+	for _, m := range msgs {
+		m.id = "msg_1"
+		m.createdAt = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+
+	data, err := json.Marshal(msgs[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "msg_1",
+		"created_at": "2025-01-02T03:04:05Z",
+		"role": "assistant",
+		"reasoning": "The user wants the image classified.",
+		"tool_calls": [{"id": "call_1", "name": "classify", "arguments": "{\"n\": 1}"}],
+		"attrs": {"model": "gpt"},
+		"provenance": {
+			"provider": "openai",
+			"model": "gpt",
+			"response_id": "chatcmpl-1",
+			"finish_reason": "tool_calls",
+			"latency": "1.5s",
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}
+	}`, string(data))
+
+	data, err = json.Marshal(msgs[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "msg_1",
+		"created_at": "2025-01-02T03:04:05Z",
+		"role": "user",
+		"content": "What is in this image?",
+		"name": "alice",
+		"images": [{"name": "cat.png", "data": "iVBORw=="}],
+		"attrs": {"agt:important": ""}
+	}`, string(data))
+}
+
+func TestExportMessagesToJSON(t *testing.T) {
+	ctx := context.Background()
+	msgs := testConversation()
+
+	s, err := ExportMessagesToJSON(ctx, msgs)
+	require.NoError(t, err)
+
+	imported, err := ImportMessagesFromJSON(s)
+	require.NoError(t, err)
+	require.Len(t, imported, len(msgs))
+
+	// Exporting again gives the same document.
+	s2, err := ExportMessagesToJSON(ctx, imported)
+	require.NoError(t, err)
+	assert.Equal(t, s, s2)
+
+	content, _ := imported[3].Content(ctx)
+	assert.Equal(t, "dynamic content", content)
+	assert.Nil(t, imported[3].contentFn)
+
+	// The original dynamic message is untouched.
+	assert.NotNil(t, msgs[3].contentFn)
+}
+
+func TestMessageJSONParts(t *testing.T) {
+	m := NewPartsMessage(RoleUser,
+		TextPart("Compare"),
+		ImagePart("a.png", []byte("a")),
+		TextPart("with"),
+		FilePart("b.pdf", "application/pdf", []byte("b")),
+		AudioPart("wav", []byte("c")),
+	)
+	m.id = ""
+	m.createdAt = time.Time{}
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"role": "user",
+		"parts": [
+			{"type": "text", "text": "Compare"},
+			{"type": "image", "name": "a.png", "data": "YQ=="},
+			{"type": "text", "text": "with"},
+			{"type": "file", "name": "b.pdf", "mime_type": "application/pdf", "data": "Yg=="},
+			{"type": "audio", "format": "wav", "data": "Yw=="}
+		]
+	}`, string(data))
+
+	var got Message
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, m.parts, got.parts)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"role": "user", "content": "hi", "parts": [{"type": "text", "text": "hi"}]}`), &got))
+	assert.Error(t, json.Unmarshal([]byte(`{"role": "user", "parts": [{"type": "video"}]}`), &got))
+}
+
+func TestImportMessagesFromJSONVersion1(t *testing.T) {
+	msgs, err := ImportMessagesFromJSON(`{"version": 1, "messages": [{"role": "user", "content": "hi", "images": [{"name": "a.png", "data": "YQ=="}]}]}`)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, []Part{TextPart("hi"), ImagePart("a.png", []byte("a"))}, msgs[0].parts)
+}
+
+func TestExportMessagesToJSONError(t *testing.T) {
+	m := NewDynamicMessage(RoleUser, func(ctx context.Context) (string, error) {
+		return "", errors.New("unavailable")
+	})
+
+	_, err := ExportMessagesToJSON(context.Background(), []*Message{m})
+	assert.Error(t, err)
+}
+
+func TestImportMessagesFromJSONVersion(t *testing.T) {
+	_, err := ImportMessagesFromJSON(`{"version": 99, "messages": []}`)
+	assert.EqualError(t, err, "unsupported conversation version 99")
+
+	_, err = ImportMessagesFromJSON(`{"messages": []}`)
+	assert.Error(t, err)
+
+	_, err = ImportMessagesFromJSON(`{"version": 1, "messages": [null]}`)
+	assert.Error(t, err)
+
+	_, err = ImportMessagesFromJSON(`not json`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventMessageAdded is emitted when a message joins the dialog, whether
+	// added by the caller or produced by a step.
+	EventMessageAdded = EventType("message_added")
+
+	// EventMessageRemoved is emitted when editing the history removes or
+	// replaces a message.
+	EventMessageRemoved = EventType("message_removed")
+
+	EventStepStarted  = EventType("step_started")
+	EventStepFinished = EventType("step_finished")
+
+	// EventProviderError is emitted each time the provider fails, including
+	// attempts that are later retried.
+	EventProviderError = EventType("provider_error")
+
+	EventToolCallStarted  = EventType("tool_call_started")
+	EventToolCallFinished = EventType("tool_call_finished")
+)
+
+// Event describes something that happened while an agent was running.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Duration is how long the work took, set on finished and error events.
+	Duration time.Duration
+
+	// Message is the message added, the result of a step or the result of a
+	// tool call. It may be nil, such as for a step that produced nothing.
+	Message *Message
+
+	// ToolCall is set on tool call events.
+	ToolCall *ToolCall
+
+	Err error
+}
+
+// Observer is called synchronously for each event, so it should return
+// quickly.
+//
+// Step, message and history events are emitted outside the step, so their
+// observers may call back into the agent, including to edit its history or
+// step it again. Events emitted by middleware, such as tool calls and
+// provider errors, arrive while the step is running: their observers must
+// not edit, step, rewind, snapshot or restore that agent, which would wait
+// for the step forever. Tool calls run in parallel report their events
+// concurrently, so an observer shared with them must be safe for concurrent
+// use.
+type Observer func(ctx context.Context, e Event)
+
+// WithObserver calls o for each event emitted by the agent, as well as those
+// emitted by middleware during its steps.
+func WithObserver(o Observer) Option {
+	return func(a *Agent) {
+		a.observers = append(a.observers, o)
+	}
+}
+
+type observersKey struct{}
+
+// Emit sends e to the observers of the agent running the current step. It
+// lets middleware report its own events; outside a step it does nothing.
+func Emit(ctx context.Context, e Event) {
+	observers, _ := ctx.Value(observersKey{}).([]Observer)
+	notify(ctx, observers, e)
+}
+
+func notify(ctx context.Context, observers []Observer, e Event) {
+	if len(observers) == 0 {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for _, o := range observers {
+		o(ctx, e)
+	}
+}
+
+func (a *Agent) emit(ctx context.Context, e Event) {
+	notify(ctx, a.observers, e)
+}
+
+// observeProvider reports errors from the provider at the end of the
+// completion chain.
+func observeProvider(c CompletionFunc) CompletionFunc {
+	return func(ctx context.Context, msgs []*Message, tdfs []ToolDef) (*Message, error) {
+		start := time.Now()
+		m, err := c(ctx, msgs, tdfs)
+		if err != nil {
+			Emit(ctx, Event{
+				Type:     EventProviderError,
+				Duration: time.Since(start),
+				Err:      err,
+			})
+		}
+		return m, err
+	}
+}
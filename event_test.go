@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects the events it observes.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) observe(ctx context.Context, e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]EventType, len(r.events))
+	for i, e := range r.events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestObserver(t *testing.T) {
+	reply := NewContentMessage(RoleAssistant, "hi")
+	mockFn := func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		Emit(ctx, Event{Type: "custom"})
+		return reply, nil
+	}
+
+	r := &recorder{}
+	a := New(mockFn, WithObserver(r.observe))
+	user := NewContentMessage(RoleUser, "hello")
+	a.AddMessage(user)
+
+	_, err := a.Step(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []EventType{
+		EventMessageAdded,
+		EventStepStarted,
+		"custom",
+		EventMessageAdded,
+		EventStepFinished,
+	}, r.types())
+
+	assert.Same(t, user, r.events[0].Message)
+	assert.Same(t, reply, r.events[3].Message)
+	assert.Same(t, reply, r.events[4].Message)
+	for _, e := range r.events {
+		assert.False(t, e.Time.IsZero())
+	}
+	assert.False(t, r.events[4].Time.Before(r.events[1].Time))
+}
+
+func TestObserverCallsBack(t *testing.T) {
+	var a *Agent
+	steps := 0
+	a = New(countingCompletion, WithObserver(func(ctx context.Context, e Event) {
+		switch e.Type {
+		case EventStepStarted:
+			_, err := a.Snapshot(ctx)
+			assert.NoError(t, err)
+		case EventMessageAdded:
+			_ = a.Messages()
+		case EventStepFinished:
+			// Drop the reply and ask again, once.
+			steps++
+			if steps == 1 {
+				_, err := a.Truncate(1)
+				require.NoError(t, err)
+				_, err = a.Step(ctx)
+				require.NoError(t, err)
+			}
+		}
+	}))
+	a.Add(RoleUser, "hello")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := a.Step(context.Background())
+		assert.NoError(t, err)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("observer calling back into the agent deadlocked")
+	}
+
+	assert.Equal(t, 2, steps)
+	assert.Equal(t, []string{"hello", "reply to 1"}, contents(t, a))
+}
+
+func TestObserverProviderError(t *testing.T) {
+	fail := errors.New("unavailable")
+	mockFn := func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		return nil, fail
+	}
+
+	r := &recorder{}
+	a := New(mockFn, WithObserver(r.observe))
+
+	_, err := a.Step(context.Background())
+	require.ErrorIs(t, err, fail)
+
+	assert.Equal(t, []EventType{EventStepStarted, EventProviderError, EventStepFinished}, r.types())
+	assert.ErrorIs(t, r.events[1].Err, fail)
+	assert.ErrorIs(t, r.events[2].Err, fail)
+	assert.Nil(t, r.events[2].Message)
+}
+
+func TestObserverNested(t *testing.T) {
+	inner := New(func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		return nil, errors.New("inner failed")
+	})
+
+	r := &recorder{}
+	outer := New(func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		_, err := inner.Step(ctx)
+		assert.Error(t, err)
+		return NewContentMessage(RoleAssistant, "ok"), nil
+	}, WithObserver(r.observe))
+
+	_, err := outer.Step(context.Background())
+	require.NoError(t, err)
+
+	// The inner agent's events aren't reported to the outer agent.
+	assert.Equal(t, []EventType{EventStepStarted, EventMessageAdded, EventStepFinished}, r.types())
+}
+
+func TestEmitOutsideStep(t *testing.T) {
+	Emit(context.Background(), Event{Type: "custom"})
+}
+
+func TestObserverRewindRestore(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{}
+	a := New(countingCompletion, WithObserver(r.observe))
+	hello := NewContentMessage(RoleUser, "hello")
+	a.AddMessage(hello)
+	a.Checkpoint("start")
+
+	reply, err := a.Step(ctx)
+	require.NoError(t, err)
+
+	r.events = nil
+	require.NoError(t, a.Rewind("start"))
+	require.Len(t, r.events, 1)
+	assert.Equal(t, EventMessageRemoved, r.events[0].Type)
+	assert.Same(t, reply, r.events[0].Message)
+
+	restored := NewContentMessage(RoleUser, "restored")
+	r.events = nil
+	require.NoError(t, a.Restore(ctx, &Snapshot{Version: SnapshotVersion, Messages: []*Message{restored}}))
+	assert.Equal(t, []EventType{EventMessageRemoved, EventMessageAdded}, r.types())
+	assert.Same(t, hello, r.events[0].Message)
+	assert.Same(t, restored, r.events[1].Message)
+}
@@ -3,17 +3,16 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/rhettg/agent"
 	"github.com/rhettg/agent/provider/openaichat"
 	"github.com/rhettg/agent/tools"
+	"github.com/rhettg/agent/tools/fstools"
 )
 
 func main() {
@@ -42,19 +41,37 @@ func main() {
 
 	p := openaichat.New(apiKey, "gpt-5-2025-08-07")
 
-	ts := tools.New()
-	createFilesystemTools(ts, cwd)
+	fsys, err := fstools.New(cwd, fstools.WithReadOnly())
+	if err != nil {
+		log.Fatalf("error creating tools: %v", err)
+	}
+
+	fsTools, err := fsys.Tools()
+	if err != nil {
+		log.Fatalf("error creating tools: %v", err)
+	}
+
+	// File reads are independent, so run batches of them concurrently. A
+	// missing file shouldn't end the exploration, so show errors to the model.
+	ts := tools.New(tools.WithParallelCalls(4), tools.WithErrorHandler(tools.SurfaceErrors))
+	if err := ts.AddTools(fsTools); err != nil {
+		log.Fatalf("error creating tools: %v", err)
+	}
 
 	a := agent.New(p, tools.WithTools(ts))
 
-	systemPrompt := fmt.Sprintf(`You are a file system explorer AI. You can list directories and read files to help answer questions about projects and codebases.
+	systemPrompt := fmt.Sprintf(`You are a file system explorer AI. You can list directories, search for files and read them to help answer questions about projects and codebases.
 
 Current working directory: %s
 
 Available tools:
 - list_directory: List files and directories in a given path
 - read_file: Read the contents of a file
+- glob: Find files by name
+- grep: Search file contents
+- stat: Describe a file or directory
 
+Paths are relative to the current working directory.
 When exploring, start with the current directory and navigate as needed to answer the user's question. Be thorough but efficient.`, cwd)
 
 	a.Add(agent.RoleSystem, systemPrompt)
@@ -83,112 +100,3 @@ When exploring, start with the current directory and navigate as needed to answe
 		}
 	}
 }
-
-func createFilesystemTools(ts *tools.Tools, basePath string) {
-	// List directory tool
-	listDirParameters := map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"path": map[string]interface{}{
-				"type":        "string",
-				"description": "The directory path to list (absolute or relative to current directory)",
-			},
-		},
-		"required": []string{"path"},
-	}
-
-	listDirTool := func(ctx context.Context, arguments string) (string, error) {
-		var args map[string]interface{}
-		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-			return "", fmt.Errorf("could not parse arguments as JSON: %w", err)
-		}
-
-		pathArg, ok := args["path"].(string)
-		if !ok {
-			return "", fmt.Errorf("path parameter must be a string")
-		}
-
-		// Convert relative paths to absolute
-		if !filepath.IsAbs(pathArg) {
-			pathArg = filepath.Join(basePath, pathArg)
-		}
-
-		entries, err := os.ReadDir(pathArg)
-		if err != nil {
-			return "", fmt.Errorf("error reading directory %s: %w", pathArg, err)
-		}
-
-		var result strings.Builder
-		result.WriteString(fmt.Sprintf("Contents of %s:\n", pathArg))
-
-		for _, entry := range entries {
-			if entry.IsDir() {
-				result.WriteString(fmt.Sprintf("  %s/\n", entry.Name()))
-			} else {
-				info, err := entry.Info()
-				if err == nil {
-					result.WriteString(fmt.Sprintf("  %s (%d bytes)\n", entry.Name(), info.Size()))
-				} else {
-					result.WriteString(fmt.Sprintf("  %s\n", entry.Name()))
-				}
-			}
-		}
-
-		return result.String(), nil
-	}
-
-	ts.Add("list_directory", "List files and directories in a given path", listDirParameters, listDirTool)
-
-	// Read file tool
-	readFileParameters := map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"path": map[string]interface{}{
-				"type":        "string",
-				"description": "The file path to read (absolute or relative to current directory)",
-			},
-			"max_lines": map[string]interface{}{
-				"type":        "number",
-				"description": "Maximum number of lines to read (default: 100)",
-			},
-		},
-		"required": []string{"path"},
-	}
-
-	readFileTool := func(ctx context.Context, arguments string) (string, error) {
-		var args map[string]interface{}
-		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-			return "", fmt.Errorf("could not parse arguments as JSON: %w", err)
-		}
-
-		pathArg, ok := args["path"].(string)
-		if !ok {
-			return "", fmt.Errorf("path parameter must be a string")
-		}
-
-		// Convert relative paths to absolute
-		if !filepath.IsAbs(pathArg) {
-			pathArg = filepath.Join(basePath, pathArg)
-		}
-
-		maxLines := 100
-		if ml, ok := args["max_lines"].(float64); ok {
-			maxLines = int(ml)
-		}
-
-		content, err := os.ReadFile(pathArg)
-		if err != nil {
-			return "", fmt.Errorf("error reading file %s: %w", pathArg, err)
-		}
-
-		lines := strings.Split(string(content), "\n")
-		if len(lines) > maxLines {
-			lines = lines[:maxLines]
-			lines = append(lines, fmt.Sprintf("... (truncated at %d lines)", maxLines))
-		}
-
-		return fmt.Sprintf("Contents of %s:\n%s", pathArg, strings.Join(lines, "\n")), nil
-	}
-
-	ts.Add("read_file", "Read the contents of a file", readFileParameters, readFileTool)
-}
@@ -31,7 +31,9 @@ func main() {
 
 	as := agentset.New()
 	ts := tools.New()
-	ts.AddTools(as.Tools())
+	if err := ts.AddTools(as.Tools()); err != nil {
+		log.Fatalf("error adding tools: %v", err)
+	}
 
 	// Adding middleware is like an onion, the first ones added will be closest
 	// to the destination provider (the API call itself.)
@@ -15,6 +15,9 @@ func main() {
 		// By default, gpt-4-vision-preview is configured with a very small
 		// default max tokens. Make it bigger.
 		openaichat.WithMaxTokens(1024),
+
+		// Keep large photos within request limits.
+		openaichat.WithImagePrep(agent.ImagePrep{MaxDimension: 2048}),
 	)
 
 	if len(os.Args) < 2 {
@@ -24,14 +27,8 @@ func main() {
 
 	a := agent.New(p)
 
-	imgName := os.Args[1]
-	imgData, err := os.ReadFile(imgName)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error reading image file: %v\n", err)
-		os.Exit(1)
-	}
-
-	im := agent.NewImageMessage(agent.RoleUser, "please explain", imgName, imgData)
+	im := agent.NewContentMessage(agent.RoleUser, "please explain")
+	im.AddPart(agent.ImagePartFrom(agent.NewImageFile(os.Args[1])))
 	a.AddMessage(im)
 
 	m, err := a.Step(context.Background())
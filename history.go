@@ -0,0 +1,284 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrMessageNotFound is returned when editing a message by an index or ID
+// that isn't in the dialog.
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrOrphanedToolResult is returned when an edit would leave a tool result
+// without the assistant message that called the tool.
+var ErrOrphanedToolResult = errors.New("tool result has no matching tool call")
+
+// ErrToolCallSeparated is returned when an edit would place another message
+// between an assistant message's tool calls and their results, which
+// providers reject.
+var ErrToolCallSeparated = errors.New("tool call separated from its results")
+
+// The editing methods below wait for a running step to finish, so a step
+// never places its result in a history that changed underneath it. Edits
+// always build a new slice, leaving checkpoints and forks untouched.
+
+// IndexOf returns the index of the message with the given ID, or -1.
+func (a *Agent) IndexOf(id string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return indexOf(a.messages, id)
+}
+
+// Insert places msgs at index i, before the message currently there. An
+// index equal to the number of messages appends them.
+func (a *Agent) Insert(i int, msgs ...*Message) error {
+	return a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		if i < 0 || i > len(cur) {
+			return nil, nil, fmt.Errorf("%w: index %d", ErrMessageNotFound, i)
+		}
+		return insertAt(cur, i, msgs), nil, nil
+	}, msgs)
+}
+
+// InsertBefore places msgs before the message with the given ID.
+func (a *Agent) InsertBefore(id string, msgs ...*Message) error {
+	return a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		i := indexOf(cur, id)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+		}
+		return insertAt(cur, i, msgs), nil, nil
+	}, msgs)
+}
+
+// Replace swaps the message at index i for m, such as to change the system
+// prompt. Replacing an assistant message must keep the tool calls that later
+// results refer to, and a tool result can only be replaced by another.
+func (a *Agent) Replace(i int, m *Message) error {
+	return a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		if i < 0 || i >= len(cur) {
+			return nil, nil, fmt.Errorf("%w: index %d", ErrMessageNotFound, i)
+		}
+		return replaceAt(cur, i, m), []*Message{cur[i]}, nil
+	}, []*Message{m})
+}
+
+// ReplaceMessage swaps the message with the given ID for m.
+func (a *Agent) ReplaceMessage(id string, m *Message) error {
+	return a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		i := indexOf(cur, id)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+		}
+		return replaceAt(cur, i, m), []*Message{cur[i]}, nil
+	}, []*Message{m})
+}
+
+// Remove deletes the message at index i and returns the messages removed.
+// Removing an assistant message also removes the results of its tool calls.
+// A tool result can only be removed on its own when no other message follows
+// its call's results; the call is left pending, so the tools middleware will
+// run it again.
+func (a *Agent) Remove(i int) ([]*Message, error) {
+	var removed []*Message
+	err := a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		if i < 0 || i >= len(cur) {
+			return nil, nil, fmt.Errorf("%w: index %d", ErrMessageNotFound, i)
+		}
+		var updated []*Message
+		updated, removed = removeAt(cur, i)
+		return updated, removed, nil
+	}, nil)
+	return removed, err
+}
+
+// RemoveMessage deletes the message with the given ID, as Remove does.
+func (a *Agent) RemoveMessage(id string) ([]*Message, error) {
+	var removed []*Message
+	err := a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		i := indexOf(cur, id)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+		}
+		var updated []*Message
+		updated, removed = removeAt(cur, i)
+		return updated, removed, nil
+	}, nil)
+	return removed, err
+}
+
+// Truncate keeps the first n messages and returns those removed.
+func (a *Agent) Truncate(n int) ([]*Message, error) {
+	var removed []*Message
+	err := a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		if n < 0 || n > len(cur) {
+			return nil, nil, fmt.Errorf("%w: index %d", ErrMessageNotFound, n)
+		}
+		removed = append([]*Message(nil), cur[n:]...)
+		return cur[:n:n], removed, nil
+	}, nil)
+	return removed, err
+}
+
+// TruncateFrom removes the message with the given ID and every message after
+// it, such as to discard a failed exchange. It returns the messages removed.
+func (a *Agent) TruncateFrom(id string) ([]*Message, error) {
+	var removed []*Message
+	err := a.edit(func(cur []*Message) ([]*Message, []*Message, error) {
+		n := indexOf(cur, id)
+		if n < 0 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+		}
+		removed = append([]*Message(nil), cur[n:]...)
+		return cur[:n:n], removed, nil
+	}, nil)
+	return removed, err
+}
+
+// edit replaces the messages with those returned by fn, after checking the
+// result keeps tool calls and their results together. Observers are told
+// about the messages removed and added once the edit is done.
+func (a *Agent) edit(fn func(cur []*Message) (updated, removed []*Message, err error), added []*Message) error {
+	for _, m := range added {
+		if m == nil {
+			return errors.New("cannot add a nil message")
+		}
+	}
+
+	a.stepMu.Lock()
+	a.mu.Lock()
+	cur := a.snapshot()
+	updated, removed, err := fn(cur)
+	if err == nil {
+		err = checkToolResults(cur, updated)
+	}
+	if err == nil {
+		a.messages = updated
+	}
+	a.mu.Unlock()
+	a.stepMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for _, m := range removed {
+		a.emit(ctx, Event{Type: EventMessageRemoved, Message: m})
+	}
+	for _, m := range added {
+		a.emit(ctx, Event{Type: EventMessageAdded, Message: m})
+	}
+
+	return nil
+}
+
+func indexOf(msgs []*Message, id string) int {
+	for i, m := range msgs {
+		if m.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func insertAt(msgs []*Message, i int, add []*Message) []*Message {
+	updated := make([]*Message, 0, len(msgs)+len(add))
+	updated = append(updated, msgs[:i]...)
+	updated = append(updated, add...)
+	return append(updated, msgs[i:]...)
+}
+
+func replaceAt(msgs []*Message, i int, m *Message) []*Message {
+	updated := make([]*Message, len(msgs))
+	copy(updated, msgs)
+	updated[i] = m
+	return updated
+}
+
+// removeAt deletes the message at i along with the results of any tool calls
+// it made.
+func removeAt(msgs []*Message, i int) (updated, removed []*Message) {
+	calls := make(map[string]bool)
+	for _, tc := range msgs[i].ToolCalls {
+		calls[tc.ID] = true
+	}
+
+	updated = make([]*Message, 0, len(msgs))
+	for j, m := range msgs {
+		if j == i || (j > i && m.Role == RoleTool && calls[m.ToolCallID]) {
+			removed = append(removed, m)
+			continue
+		}
+		updated = append(updated, m)
+	}
+
+	return updated, removed
+}
+
+// orphans returns the tool results that don't follow an assistant message
+// calling the tool.
+func orphans(msgs []*Message) map[*Message]bool {
+	calls := make(map[string]bool)
+	found := make(map[*Message]bool)
+	for _, m := range msgs {
+		switch {
+		case m.Role == RoleAssistant:
+			for _, tc := range m.ToolCalls {
+				calls[tc.ID] = true
+			}
+		case m.Role == RoleTool && m.ToolCallID != "" && !calls[m.ToolCallID]:
+			found[m] = true
+		}
+	}
+	return found
+}
+
+// separators returns the messages that come between an assistant message
+// and the results of its tool calls, while some of those calls are still
+// unanswered.
+func separators(msgs []*Message) map[*Message]bool {
+	open := make(map[string]bool)
+	found := make(map[*Message]bool)
+	for _, m := range msgs {
+		if m.Role == RoleTool {
+			delete(open, m.ToolCallID)
+			continue
+		}
+
+		if len(open) > 0 {
+			found[m] = true
+		}
+
+		if m.Role == RoleAssistant {
+			open = make(map[string]bool, len(m.ToolCalls))
+			for _, tc := range m.ToolCalls {
+				open[tc.ID] = true
+			}
+		}
+	}
+	return found
+}
+
+// checkToolResults rejects updated if it orphans a tool result or separates
+// tool calls from their results in a way cur didn't already, so a history
+// that was loaded inconsistent can still be edited.
+func checkToolResults(cur, updated []*Message) error {
+	before := orphans(cur)
+	for m := range orphans(updated) {
+		if !before[m] {
+			return fmt.Errorf("%w: %s", ErrOrphanedToolResult, m.ToolCallID)
+		}
+	}
+
+	before = separators(cur)
+	for m := range separators(updated) {
+		if !before[m] {
+			return fmt.Errorf("%w: %s message %s", ErrToolCallSeparated, m.Role, m.id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolExchange returns a dialog where the assistant calls two tools.
+func toolExchange() []*Message {
+	call := NewContentMessage(RoleAssistant, "")
+	call.ToolCalls = []ToolCall{{ID: "call_1", Name: "a"}, {ID: "call_2", Name: "b"}}
+
+	r1 := NewContentMessage(RoleTool, "one")
+	r1.ToolCallID = "call_1"
+	r2 := NewContentMessage(RoleTool, "two")
+	r2.ToolCallID = "call_2"
+
+	return []*Message{
+		NewContentMessage(RoleSystem, "system"),
+		NewContentMessage(RoleUser, "question"),
+		call,
+		r1,
+		r2,
+		NewContentMessage(RoleAssistant, "answer"),
+	}
+}
+
+func newHistoryAgent(msgs []*Message, opts ...Option) *Agent {
+	a := New(nil, opts...)
+	for _, m := range msgs {
+		a.AddMessage(m)
+	}
+	return a
+}
+
+func TestInsertReplace(t *testing.T) {
+	a := newHistoryAgent(toolExchange())
+
+	require.NoError(t, a.Insert(1, NewContentMessage(RoleUser, "context")))
+	require.NoError(t, a.Replace(0, NewContentMessage(RoleSystem, "new system")))
+	assert.Equal(t, []string{"new system", "context", "question", "", "one", "two", "answer"}, contents(t, a))
+
+	last := a.Messages()[6]
+	require.NoError(t, a.InsertBefore(last.ID(), NewContentMessage(RoleUser, "more")))
+	require.NoError(t, a.ReplaceMessage(last.ID(), NewContentMessage(RoleAssistant, "better")))
+	assert.Equal(t, []string{"new system", "context", "question", "", "one", "two", "more", "better"}, contents(t, a))
+
+	// Inserting at the end appends.
+	require.NoError(t, a.Insert(8, NewContentMessage(RoleUser, "end")))
+	assert.Len(t, a.Messages(), 9)
+
+	assert.ErrorIs(t, a.Insert(10, NewContentMessage(RoleUser, "x")), ErrMessageNotFound)
+	assert.ErrorIs(t, a.Replace(-1, NewContentMessage(RoleUser, "x")), ErrMessageNotFound)
+	assert.ErrorIs(t, a.ReplaceMessage("msg_missing", NewContentMessage(RoleUser, "x")), ErrMessageNotFound)
+	assert.Error(t, a.Insert(0, nil))
+}
+
+func TestRemove(t *testing.T) {
+	msgs := toolExchange()
+	a := newHistoryAgent(msgs)
+
+	// Removing the assistant message removes its tool results too.
+	removed, err := a.Remove(2)
+	require.NoError(t, err)
+	assert.Equal(t, msgs[2:5], removed)
+	assert.Equal(t, []string{"system", "question", "answer"}, contents(t, a))
+
+	removed, err = a.RemoveMessage(msgs[0].ID())
+	require.NoError(t, err)
+	assert.Equal(t, msgs[:1], removed)
+	assert.Equal(t, []string{"question", "answer"}, contents(t, a))
+
+	_, err = a.RemoveMessage(msgs[0].ID())
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+	_, err = a.Remove(2)
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
+
+func TestRemoveToolResult(t *testing.T) {
+	msgs := toolExchange()
+	a := newHistoryAgent(msgs)
+
+	// The answer would follow a call that is no longer answered.
+	_, err := a.Remove(3)
+	assert.ErrorIs(t, err, ErrToolCallSeparated)
+
+	// Once nothing follows the results, the call is left pending.
+	_, err = a.Truncate(5)
+	require.NoError(t, err)
+	removed, err := a.Remove(3)
+	require.NoError(t, err)
+	assert.Equal(t, msgs[3:4], removed)
+	assert.Equal(t, []string{"system", "question", "", "two"}, contents(t, a))
+}
+
+func TestTruncate(t *testing.T) {
+	msgs := toolExchange()
+	a := newHistoryAgent(msgs)
+
+	removed, err := a.TruncateFrom(msgs[4].ID())
+	require.NoError(t, err)
+	assert.Equal(t, msgs[4:], removed)
+	assert.Equal(t, []string{"system", "question", "", "one"}, contents(t, a))
+
+	removed, err = a.Truncate(2)
+	require.NoError(t, err)
+	assert.Equal(t, msgs[2:4], removed)
+	assert.Equal(t, []string{"system", "question"}, contents(t, a))
+
+	_, err = a.Truncate(3)
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+	_, err = a.TruncateFrom("msg_missing")
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
+
+func TestEditOrphanedToolResult(t *testing.T) {
+	msgs := toolExchange()
+	a := newHistoryAgent(msgs)
+
+	// Dropping the calls that results refer to is rejected.
+	assert.ErrorIs(t, a.Replace(2, NewContentMessage(RoleAssistant, "no calls")), ErrOrphanedToolResult)
+
+	// A result can't be inserted before its call.
+	r := NewContentMessage(RoleTool, "early")
+	r.ToolCallID = "call_1"
+	assert.ErrorIs(t, a.Insert(1, r), ErrOrphanedToolResult)
+	assert.ErrorIs(t, a.Insert(0, r), ErrOrphanedToolResult)
+
+	// Nothing can come between the calls and their results.
+	assert.ErrorIs(t, a.Insert(3, NewContentMessage(RoleUser, "interrupt")), ErrToolCallSeparated)
+	assert.ErrorIs(t, a.Insert(4, NewContentMessage(RoleAssistant, "interrupt")), ErrToolCallSeparated)
+	assert.ErrorIs(t, a.Replace(3, NewContentMessage(RoleUser, "not a result")), ErrToolCallSeparated)
+
+	// Nothing changed.
+	assert.Equal(t, msgs, a.Messages())
+
+	// Keeping the calls is fine.
+	call := NewMessageFromMessage(msgs[2])
+	call.SetReasoning("edited")
+	require.NoError(t, a.Replace(2, call))
+
+	// A result after its call can be inserted.
+	require.NoError(t, a.Insert(5, r))
+}
+
+func TestEditPendingToolCalls(t *testing.T) {
+	msgs := toolExchange()
+	a := newHistoryAgent(msgs[:4])
+
+	// call_2 hasn't been answered yet, so nothing else can follow it.
+	assert.ErrorIs(t, a.Insert(4, NewContentMessage(RoleUser, "more")), ErrToolCallSeparated)
+
+	require.NoError(t, a.Insert(4, msgs[4]))
+	require.NoError(t, a.Insert(5, NewContentMessage(RoleUser, "more")))
+}
+
+func TestEditExistingSeparation(t *testing.T) {
+	msgs := toolExchange()
+	user := NewContentMessage(RoleUser, "interrupt")
+	a := newHistoryAgent([]*Message{msgs[2], msgs[3], user, msgs[4]})
+
+	// A separation loaded with the history doesn't block other edits.
+	require.NoError(t, a.Replace(0, NewMessageFromMessage(msgs[2])))
+	require.NoError(t, a.Insert(4, NewContentMessage(RoleUser, "more")))
+}
+
+func TestEditExistingOrphan(t *testing.T) {
+	orphan := NewContentMessage(RoleTool, "orphan")
+	orphan.ToolCallID = "call_9"
+	a := newHistoryAgent([]*Message{orphan, NewContentMessage(RoleUser, "hi")})
+
+	// An orphan loaded with the history doesn't block other edits.
+	require.NoError(t, a.Insert(2, NewContentMessage(RoleUser, "more")))
+	_, err := a.Remove(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hi", "more"}, contents(t, a))
+}
+
+func TestEditCheckpoint(t *testing.T) {
+	a := newHistoryAgent(toolExchange())
+	a.Checkpoint("start")
+
+	fork, err := a.Fork("fork")
+	require.NoError(t, err)
+
+	require.NoError(t, a.Replace(0, NewContentMessage(RoleSystem, "new system")))
+	_, err = a.Remove(1)
+	require.NoError(t, err)
+
+	// The fork and checkpoint keep the original history.
+	assert.Equal(t, []string{"system", "question", "", "one", "two", "answer"}, contents(t, fork))
+	require.NoError(t, a.Rewind("start"))
+	assert.Equal(t, []string{"system", "question", "", "one", "two", "answer"}, contents(t, a))
+}
+
+func TestEditObserver(t *testing.T) {
+	msgs := toolExchange()
+	r := &recorder{}
+	a := newHistoryAgent(msgs, WithObserver(r.observe))
+	r.events = nil
+
+	m := NewContentMessage(RoleSystem, "new system")
+	require.NoError(t, a.Replace(0, m))
+
+	assert.Equal(t, []EventType{EventMessageRemoved, EventMessageAdded}, r.types())
+	assert.Same(t, msgs[0], r.events[0].Message)
+	assert.Same(t, m, r.events[1].Message)
+}
+
+func TestEditWaitsForStep(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	a := New(func(ctx context.Context, msgs []*Message, fns []ToolDef) (*Message, error) {
+		close(started)
+		<-release
+		return NewContentMessage(RoleAssistant, "reply"), nil
+	})
+	a.Add(RoleUser, "hello")
+
+	done := make(chan error)
+	go func() {
+		_, err := a.Step(context.Background())
+		done <- err
+	}()
+	<-started
+
+	edited := make(chan error)
+	go func() {
+		_, err := a.Truncate(0)
+		edited <- err
+	}()
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-edited)
+
+	// The truncate ran after the step placed its reply.
+	assert.Empty(t, a.Messages())
+}
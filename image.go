@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	// Register decoders for the formats PrepareImage understands.
+	_ "image/gif"
+)
+
+// ImageLoader returns image data on demand, such as by reading a file.
+type ImageLoader func(ctx context.Context) ([]byte, error)
+
+type Image struct {
+	Name string
+	Data []byte
+
+	// URL references a remote image. Providers that accept image URLs pass it
+	// through rather than sending the data.
+	URL string
+
+	// MIMEType overrides the type detected from the data.
+	MIMEType string
+
+	// Detail hints how closely the model should look at the image, such as
+	// "low", "high" or "auto". Providers without the concept ignore it.
+	Detail string
+
+	// Loader provides Data when it isn't set. It is called each time the data
+	// is needed.
+	Loader ImageLoader
+}
+
+// NewImageURL references an image by URL.
+func NewImageURL(u string) Image {
+	return Image{Name: path.Base(urlPath(u)), URL: u}
+}
+
+// NewImageFile references an image on disk. The file is read when the image
+// is sent, not when it is added to a message.
+func NewImageFile(name string) Image {
+	return Image{
+		Name: filepath.Base(name),
+		Loader: func(ctx context.Context) ([]byte, error) {
+			return os.ReadFile(name)
+		},
+	}
+}
+
+// ImagePartFrom creates a part holding the image.
+func ImagePartFrom(img Image) Part {
+	return Part{Type: PartImage, Image: &img}
+}
+
+// Resolve returns a copy of the image with Data loaded. Images referenced by
+// URL are returned unchanged.
+func (img Image) Resolve(ctx context.Context) (Image, error) {
+	if img.Data != nil || img.Loader == nil {
+		return img, nil
+	}
+
+	data, err := img.Loader(ctx)
+	if err != nil {
+		return Image{}, fmt.Errorf("error loading image %s: %w", img.Name, err)
+	}
+
+	img.Data = data
+	img.Loader = nil
+	return img, nil
+}
+
+// ContentType returns the MIME type of the image. Unless MIMEType is set, it
+// is detected from the data, falling back to the extension of the name or
+// URL. It returns an empty string if the type can't be determined.
+func (img Image) ContentType() string {
+	if img.MIMEType != "" {
+		return img.MIMEType
+	}
+
+	if len(img.Data) > 0 {
+		if t := http.DetectContentType(img.Data); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+
+	for _, name := range []string{img.Name, urlPath(img.URL)} {
+		if t := mime.TypeByExtension(path.Ext(name)); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+
+	return ""
+}
+
+func urlPath(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return pu.Path
+}
+
+// ImagePrep describes how to prepare images before sending them to a model.
+type ImagePrep struct {
+	// MaxDimension limits the width and height of the image in pixels.
+	// Larger images are scaled down, keeping their aspect ratio. Zero means
+	// no limit.
+	MaxDimension int
+
+	// Format re-encodes images as "jpeg" or "png". Empty keeps the original
+	// format, unless the image is resized and can't be written in it.
+	Format string
+
+	// Quality is the JPEG quality, from 1 to 100. Defaults to 85.
+	Quality int
+
+	// Detail is set on images that don't have one.
+	Detail string
+
+	// MaxPixels rejects images with more pixels than this rather than
+	// decoding them, since a small compressed file can expand to gigabytes.
+	// Defaults to DefaultMaxPixels.
+	MaxPixels int
+}
+
+// DefaultMaxPixels is the largest image PrepareImage decodes when
+// ImagePrep.MaxPixels isn't set.
+const DefaultMaxPixels = 40_000_000
+
+// PrepareImage loads, scales and re-encodes the image as described by prep.
+// Images referenced by URL, and images in formats the standard library can't
+// decode, are passed through with only their detail set.
+func PrepareImage(ctx context.Context, img Image, prep ImagePrep) (Image, error) {
+	img, err := img.Resolve(ctx)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if img.Detail == "" {
+		img.Detail = prep.Detail
+	}
+
+	if len(img.Data) == 0 || (prep.MaxDimension == 0 && prep.Format == "") {
+		return img, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return img, nil
+	}
+
+	resize := prep.MaxDimension > 0 && max(cfg.Width, cfg.Height) > prep.MaxDimension
+	if !resize && (prep.Format == "" || prep.Format == format) {
+		return img, nil
+	}
+
+	maxPixels := prep.MaxPixels
+	if maxPixels <= 0 {
+		maxPixels = DefaultMaxPixels
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Image{}, fmt.Errorf("image %s is too large to decode (%dx%d)", img.Name, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return img, nil
+	}
+
+	if resize {
+		src = downscale(src, prep.MaxDimension)
+	}
+
+	outFormat := prep.Format
+	if outFormat == "" {
+		outFormat = format
+		if outFormat != "jpeg" && outFormat != "png" {
+			outFormat = "png"
+		}
+	}
+
+	var buf bytes.Buffer
+	switch outFormat {
+	case "jpeg":
+		quality := prep.Quality
+		if quality == 0 {
+			quality = 85
+		}
+		err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(&buf, src)
+	default:
+		return Image{}, fmt.Errorf("unsupported image format %q", outFormat)
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("error encoding image %s: %w", img.Name, err)
+	}
+
+	ext := ".png"
+	if outFormat == "jpeg" {
+		ext = ".jpg"
+	}
+
+	img.Name = strings.TrimSuffix(img.Name, path.Ext(img.Name)) + ext
+	img.Data = buf.Bytes()
+	img.MIMEType = "image/" + outFormat
+	return img, nil
+}
+
+// downscale shrinks the image so neither side exceeds maxDim, averaging the
+// source pixels covered by each destination pixel.
+func downscale(src image.Image, maxDim int) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+
+	dw, dh := maxDim, maxDim
+	if sw > sh {
+		dh = max(1, sh*maxDim/sw)
+	} else {
+		dw = max(1, sw*maxDim/sh)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, sb.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := rgba.Pix[y*rgba.Stride+x0*4 : y*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (x1 - x0) * (y1 - y0)
+			o := dy*dst.Stride + dx*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	return dst
+}
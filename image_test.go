@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestImageContentType(t *testing.T) {
+	data := testPNG(t, 1, 1)
+
+	// The data wins over a misleading name.
+	assert.Equal(t, "image/png", Image{Name: "photo.jpg", Data: data}.ContentType())
+	assert.Equal(t, "image/jpeg", Image{Name: "photo.jpg", Data: []byte("????")}.ContentType())
+	assert.Equal(t, "image/gif", NewImageURL("https://example.com/a/cat.gif?size=large").ContentType())
+	assert.Equal(t, "image/webp", Image{Data: data, MIMEType: "image/webp"}.ContentType())
+	assert.Equal(t, "", Image{Name: "blob"}.ContentType())
+}
+
+func TestImageResolve(t *testing.T) {
+	ctx := context.Background()
+
+	name := filepath.Join(t.TempDir(), "cat.png")
+	require.NoError(t, os.WriteFile(name, []byte("png"), 0o644))
+
+	img := NewImageFile(name)
+	assert.Equal(t, "cat.png", img.Name)
+	assert.Nil(t, img.Data)
+
+	loaded, err := img.Resolve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), loaded.Data)
+	assert.Nil(t, loaded.Loader)
+
+	_, err = NewImageFile(filepath.Join(t.TempDir(), "missing.png")).Resolve(ctx)
+	assert.Error(t, err)
+
+	u := NewImageURL("https://example.com/cat.png")
+	resolved, err := u.Resolve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, u.URL, resolved.URL)
+	assert.Nil(t, resolved.Data)
+}
+
+func TestPrepareImage(t *testing.T) {
+	ctx := context.Background()
+	img := Image{Name: "wide.png", Data: testPNG(t, 200, 100)}
+
+	prepped, err := PrepareImage(ctx, img, ImagePrep{MaxDimension: 50, Detail: "low"})
+	require.NoError(t, err)
+	assert.Equal(t, "wide.png", prepped.Name)
+	assert.Equal(t, "low", prepped.Detail)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(prepped.Data))
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, 50, cfg.Width)
+	assert.Equal(t, 25, cfg.Height)
+
+	// Colors survive the averaging.
+	decoded, err := png.Decode(bytes.NewReader(prepped.Data))
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(10, 10).RGBA()
+	assert.Equal(t, []uint32{200, 100, 50}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	prepped, err = PrepareImage(ctx, img, ImagePrep{Format: "jpeg", Quality: 50})
+	require.NoError(t, err)
+	assert.Equal(t, "wide.jpg", prepped.Name)
+	assert.Equal(t, "image/jpeg", prepped.ContentType())
+
+	// Small images are left alone.
+	prepped, err = PrepareImage(ctx, img, ImagePrep{MaxDimension: 500})
+	require.NoError(t, err)
+	assert.Equal(t, img.Data, prepped.Data)
+
+	// So are images that can't be decoded, and URLs.
+	unknown := Image{Name: "a.webp", Data: []byte("RIFF")}
+	prepped, err = PrepareImage(ctx, unknown, ImagePrep{MaxDimension: 10})
+	require.NoError(t, err)
+	assert.Equal(t, unknown, prepped)
+
+	prepped, err = PrepareImage(ctx, NewImageURL("https://example.com/a.png"), ImagePrep{MaxDimension: 10, Detail: "high"})
+	require.NoError(t, err)
+	assert.Equal(t, "high", prepped.Detail)
+
+	failing := Image{Name: "a.png", Loader: func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("gone")
+	}}
+	_, err = PrepareImage(ctx, failing, ImagePrep{})
+	assert.Error(t, err)
+}
+
+func TestPrepareImageFormats(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	pal := image.NewPaletted(image.Rect(0, 0, 20, 10), color.Palette{color.Black, color.White})
+	require.NoError(t, gif.Encode(&buf, pal, nil))
+	anim := Image{Name: "anim.gif", Data: buf.Bytes()}
+
+	// An image that isn't resized keeps its format.
+	prepped, err := PrepareImage(ctx, anim, ImagePrep{MaxDimension: 100})
+	require.NoError(t, err)
+	assert.Equal(t, anim, prepped)
+
+	// A resized GIF is written as PNG.
+	prepped, err = PrepareImage(ctx, anim, ImagePrep{MaxDimension: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "anim.png", prepped.Name)
+	assert.Equal(t, "image/png", prepped.ContentType())
+
+	// Images too large to decode safely are rejected from their header.
+	big := Image{Name: "big.png", Data: testPNG(t, 200, 100)}
+	_, err = PrepareImage(ctx, big, ImagePrep{MaxDimension: 50, MaxPixels: 10000})
+	assert.ErrorContains(t, err, "too large")
+}
+
+func TestExportMessagesImageReferences(t *testing.T) {
+	ctx := context.Background()
+
+	name := filepath.Join(t.TempDir(), "cat.png")
+	require.NoError(t, os.WriteFile(name, []byte("png"), 0o644))
+
+	m := NewContentMessage(RoleUser, "Compare")
+	m.AddPart(ImagePartFrom(Image{Name: "cat.png", URL: "https://example.com/cat.png", Detail: "low"}))
+	m.AddPart(ImagePartFrom(NewImageFile(name)))
+
+	want := []Image{
+		{Name: "cat.png", URL: "https://example.com/cat.png", Detail: "low"},
+		{Name: "cat.png", Data: []byte("png")},
+	}
+
+	s, err := ExportMessagesToJSON(ctx, []*Message{m})
+	require.NoError(t, err)
+	imported, err := ImportMessagesFromJSON(s)
+	require.NoError(t, err)
+	assert.Equal(t, want, imported[0].Images())
+
+	s, err = ExportMessagesToYAML(ctx, []*Message{m})
+	require.NoError(t, err)
+	imported, err = ImportMessagesFromYAML(s)
+	require.NoError(t, err)
+	assert.Equal(t, want, imported[0].Images())
+
+	// The original still loads lazily.
+	assert.NotNil(t, m.Images()[1].Loader)
+}
@@ -4,49 +4,97 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type ContentFn func(context.Context) (string, error)
 
-type Image struct {
-	Name string
-	Data []byte
-}
-
 type Message struct {
-	Role    Role
-	content string
+	Role Role
+
+	id         string
+	createdAt  time.Time
+	provenance *Provenance
+
+	// parts holds the content in order. Dynamic messages have their text
+	// provided by contentFn instead.
+	parts []Part
 
-	imageData []Image
+	// reasoning is the thinking a model did before answering.
+	reasoning string
 
 	// TODO: add name concept which is part of openai api anyway. Might be useful.
 	Name string
 
 	// Tool calling support
-	ToolCalls []ToolCall  // Only for assistant messages
+	ToolCalls  []ToolCall // Only for assistant messages
 	ToolCallID string     // Only for tool response messages
 
 	contentFn ContentFn
 	attrs     map[string]string
 }
 
+// Content returns the text of the message. When there are several text parts
+// they are joined by newlines.
 func (m *Message) Content(ctx context.Context) (string, error) {
 	if m.contentFn != nil {
 		return m.contentFn(ctx)
 	}
-	return m.content, nil
+	return joinText(m.parts), nil
+}
+
+// Parts returns the content of the message in order. For dynamic messages
+// the text comes first, followed by any other parts.
+func (m *Message) Parts(ctx context.Context) ([]Part, error) {
+	if m.contentFn == nil {
+		p := make([]Part, len(m.parts))
+		copy(p, m.parts)
+		return p, nil
+	}
+
+	content, err := m.contentFn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := []Part{TextPart(content)}
+	for _, part := range m.parts {
+		if part.Type != PartText {
+			p = append(p, part)
+		}
+	}
+	return p, nil
+}
+
+// Reasoning returns the thinking or reasoning summary the model produced
+// alongside its answer, if the provider exposes it. It is not part of
+// Content.
+func (m *Message) Reasoning() string {
+	return m.reasoning
+}
+
+func (m *Message) SetReasoning(reasoning string) {
+	m.reasoning = reasoning
+}
+
+func (m *Message) AddPart(p Part) {
+	m.parts = append(m.parts, p)
 }
 
 func (m *Message) Images() []Image {
-	i := make([]Image, len(m.imageData))
-	copy(i, m.imageData)
+	var i []Image
+	for _, p := range m.parts {
+		if p.Type == PartImage && p.Image != nil {
+			i = append(i, *p.Image)
+		}
+	}
 	return i
 }
 
 func (m *Message) AddImage(name string, data []byte) {
-	m.imageData = append(m.imageData, Image{Name: name, Data: data})
+	m.AddPart(ImagePart(name, data))
 }
 
 func (m *Message) SetAttr(key, value string) {
@@ -71,22 +119,35 @@ func (m *Message) HasTag(key string) bool {
 }
 
 func newMessage() *Message {
-	return &Message{attrs: make(map[string]string)}
+	return &Message{
+		id:        newMessageID(),
+		createdAt: time.Now(),
+		attrs:     make(map[string]string),
+	}
 }
 
 func NewContentMessage(role Role, content string) *Message {
 	m := newMessage()
 	m.Role = role
-	m.content = content
+	if content != "" {
+		m.AddPart(TextPart(content))
+	}
 
 	return m
 }
 
 func NewImageMessage(role Role, content string, imageName string, imageData []byte) *Message {
+	m := NewContentMessage(role, content)
+	m.AddImage(imageName, imageData)
+	return m
+}
+
+// NewPartsMessage creates a message from content parts, such as text
+// interleaved with images.
+func NewPartsMessage(role Role, parts ...Part) *Message {
 	m := newMessage()
 	m.Role = role
-	m.content = content
-	m.AddImage(imageName, imageData)
+	m.parts = append(m.parts, parts...)
 	return m
 }
 
@@ -99,15 +160,20 @@ func NewDynamicMessage(role Role, contentFn ContentFn) *Message {
 
 func NewMessageFromMessage(m *Message) *Message {
 	nm := newMessage()
+	nm.id = m.id
+	nm.createdAt = m.createdAt
+	if m.provenance != nil {
+		nm.SetProvenance(*m.provenance)
+	}
 	nm.Role = m.Role
-	nm.content = m.content
+	nm.parts = make([]Part, len(m.parts))
+	copy(nm.parts, m.parts)
+	nm.reasoning = m.reasoning
 	nm.Name = m.Name
 	nm.ToolCalls = make([]ToolCall, len(m.ToolCalls))
 	copy(nm.ToolCalls, m.ToolCalls)
 	nm.ToolCallID = m.ToolCallID
 	nm.contentFn = m.contentFn
-	nm.imageData = make([]Image, len(m.imageData))
-	copy(nm.imageData, m.imageData)
 
 	for k, v := range m.attrs {
 		nm.attrs[k] = v
@@ -128,37 +194,103 @@ func (m *Message) GetFirstToolCall() *ToolCall {
 	return nil
 }
 
+type yamlToolCall struct {
+	ID        string `yaml:"ID"`
+	Name      string `yaml:"Name"`
+	Arguments string `yaml:"Arguments"`
+}
+
+type yamlImage struct {
+	Name     string `yaml:"name"`
+	Data     string `yaml:"data,omitempty"`
+	URL      string `yaml:"url,omitempty"`
+	MIMEType string `yaml:"mime_type,omitempty"`
+	Detail   string `yaml:"detail,omitempty"`
+}
+
+type yamlPart struct {
+	Type     PartType `yaml:"type"`
+	Text     string   `yaml:"text,omitempty"`
+	Name     string   `yaml:"name,omitempty"`
+	MIMEType string   `yaml:"mime_type,omitempty"`
+	Format   string   `yaml:"format,omitempty"`
+	Data     string   `yaml:"data,omitempty"`
+	URL      string   `yaml:"url,omitempty"`
+	Detail   string   `yaml:"detail,omitempty"`
+}
+
+// yamlMessage holds content either as Content and Images or, when the
+// message interleaves parts, as Parts.
+type yamlMessage struct {
+	ID         string            `yaml:"ID,omitempty"`
+	CreatedAt  string            `yaml:"CreatedAt,omitempty"`
+	Role       Role              `yaml:"Role"`
+	Content    string            `yaml:"Content"`
+	Reasoning  string            `yaml:"Reasoning,omitempty"`
+	Parts      []yamlPart        `yaml:"Parts,omitempty"`
+	Name       string            `yaml:"Name,omitempty"`
+	ToolCalls  []yamlToolCall    `yaml:"ToolCalls,omitempty"`
+	ToolCallID string            `yaml:"ToolCallID,omitempty"`
+	Images     []yamlImage       `yaml:"Images,omitempty"`
+	Attrs      map[string]string `yaml:"Attrs,omitempty"`
+	Provenance *provenanceRecord `yaml:"Provenance,omitempty"`
+}
+
 func ExportMessagesToYAML(ctx context.Context, messages []*Message) (string, error) {
-	yamlMessages := make([]map[string]interface{}, len(messages))
+	yamlMessages := make([]yamlMessage, len(messages))
 
 	for i, m := range messages {
-		content, err := m.Content(ctx)
+		parts, err := resolveParts(ctx, m)
 		if err != nil {
-			return "", fmt.Errorf("error getting message content: %w", err)
+			return "", err
 		}
-		yamlMessage := make(map[string]interface{})
-		yamlMessage["Role"] = m.Role
-		yamlMessage["Content"] = content
-
-		if len(m.imageData) > 0 {
-			yamlMessage["Images"] = make([]map[string]string, 0, len(m.imageData))
-			images := make([]interface{}, 0, len(m.imageData))
-			for _, img := range m.imageData {
-				dst := make([]byte, base64.StdEncoding.EncodedLen(len(img.Data)))
-				base64.StdEncoding.Encode(dst, img.Data)
-				img := map[string]string{
-					"name": img.Name,
-					"data": string(dst),
-				}
-				images = append(images, img)
+
+		ym := yamlMessage{
+			ID:         m.id,
+			CreatedAt:  encodeTime(m.createdAt),
+			Provenance: encodeProvenance(m.provenance),
+			Role:       m.Role,
+			Reasoning:  m.reasoning,
+			Name:       m.Name,
+			ToolCallID: m.ToolCallID,
+		}
+
+		if content, images, ok := splitSimple(parts); ok {
+			ym.Content = content
+			for _, img := range images {
+				ym.Images = append(ym.Images, yamlImage{
+					Name:     img.Name,
+					Data:     base64.StdEncoding.EncodeToString(img.Data),
+					URL:      img.URL,
+					MIMEType: img.MIMEType,
+					Detail:   img.Detail,
+				})
+			}
+		} else {
+			for _, p := range parts {
+				pj := encodePart(p)
+				ym.Parts = append(ym.Parts, yamlPart{
+					Type:     pj.Type,
+					Text:     pj.Text,
+					Name:     pj.Name,
+					MIMEType: pj.MIMEType,
+					Format:   pj.Format,
+					Data:     base64.StdEncoding.EncodeToString(pj.Data),
+					URL:      pj.URL,
+					Detail:   pj.Detail,
+				})
 			}
-			yamlMessage["Images"] = images
 		}
 
-		// TODO: Functions
-		// TODO: attrs
+		for _, tc := range m.ToolCalls {
+			ym.ToolCalls = append(ym.ToolCalls, yamlToolCall(tc))
+		}
+
+		if len(m.attrs) > 0 {
+			ym.Attrs = m.attrs
+		}
 
-		yamlMessages[i] = yamlMessage
+		yamlMessages[i] = ym
 	}
 
 	bytes, err := yaml.Marshal(yamlMessages)
@@ -169,19 +301,88 @@ func ExportMessagesToYAML(ctx context.Context, messages []*Message) (string, err
 	return string(bytes), nil
 }
 
+// ImportMessagesFromYAML reads messages written by ExportMessagesToYAML.
+// Unknown fields are ignored.
 func ImportMessagesFromYAML(yamlString string) ([]*Message, error) {
-	var yamlMessages []map[string]interface{}
+	var yamlMessages []yamlMessage
 	if err := yaml.Unmarshal([]byte(yamlString), &yamlMessages); err != nil {
 		return nil, fmt.Errorf("error unmarshaling YAML: %w", err)
 	}
 
-	var messages []*Message
-	for _, ym := range yamlMessages {
-		messages = append(messages, &Message{
-			Role:    Role(ym["Role"].(string)),
-			content: ym["Content"].(string),
-		})
+	messages := make([]*Message, 0, len(yamlMessages))
+	for i, ym := range yamlMessages {
+		if ym.Role == "" {
+			return nil, fmt.Errorf("message %d: missing Role", i)
+		}
+
+		if len(ym.Parts) > 0 && (ym.Content != "" || len(ym.Images) > 0) {
+			return nil, fmt.Errorf("message %d: Parts cannot be combined with Content or Images", i)
+		}
+
+		m := NewContentMessage(ym.Role, ym.Content)
+		if err := m.setIdentity(ym.ID, ym.CreatedAt, ym.Provenance); err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		m.reasoning = ym.Reasoning
+		m.Name = ym.Name
+		m.ToolCallID = ym.ToolCallID
+
+		for j, tc := range ym.ToolCalls {
+			if tc.ID == "" || tc.Name == "" {
+				return nil, fmt.Errorf("message %d: tool call %d: missing ID or Name", i, j)
+			}
+			m.ToolCalls = append(m.ToolCalls, ToolCall(tc))
+		}
+
+		for j, img := range ym.Images {
+			data, err := decodeData(img.Data)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: image %d: invalid data: %w", i, j, err)
+			}
+			m.AddPart(ImagePartFrom(Image{
+				Name:     img.Name,
+				Data:     data,
+				URL:      img.URL,
+				MIMEType: img.MIMEType,
+				Detail:   img.Detail,
+			}))
+		}
+
+		for j, yp := range ym.Parts {
+			data, err := decodeData(yp.Data)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: part %d: invalid data: %w", i, j, err)
+			}
+			p, err := decodePart(partJSON{
+				Type:     yp.Type,
+				Text:     yp.Text,
+				Name:     yp.Name,
+				MIMEType: yp.MIMEType,
+				Format:   yp.Format,
+				Data:     data,
+				URL:      yp.URL,
+				Detail:   yp.Detail,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("message %d: part %d: %w", i, j, err)
+			}
+			m.AddPart(p)
+		}
+
+		for k, v := range ym.Attrs {
+			m.attrs[k] = v
+		}
+
+		messages = append(messages, m)
 	}
 
 	return messages, nil
 }
+
+// decodeData decodes base64 data, leaving it nil when empty.
+func decodeData(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
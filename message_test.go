@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMessagesToYAML(t *testing.T) {
+	ctx := context.Background()
+	msgs := testConversation()
+
+	s, err := ExportMessagesToYAML(ctx, msgs)
+	require.NoError(t, err)
+
+	imported, err := ImportMessagesFromYAML(s)
+	require.NoError(t, err)
+	require.Len(t, imported, len(msgs))
+
+	for i, m := range msgs {
+		got := imported[i]
+
+		wantContent, _ := m.Content(ctx)
+		gotContent, _ := got.Content(ctx)
+		assert.Equal(t, wantContent, gotContent)
+		assert.Equal(t, m.ID(), got.ID())
+		assert.True(t, m.CreatedAt().Equal(got.CreatedAt()))
+		assert.Equal(t, m.Provenance(), got.Provenance())
+		assert.Equal(t, m.Role, got.Role)
+		assert.Equal(t, m.Reasoning(), got.Reasoning())
+		assert.Equal(t, m.Name, got.Name)
+		assert.Equal(t, m.ToolCalls, got.ToolCalls)
+		assert.Equal(t, m.ToolCallID, got.ToolCallID)
+		assert.Equal(t, m.Images(), got.Images())
+		assert.Equal(t, len(m.attrs), len(got.attrs))
+		for k, v := range m.attrs {
+			assert.Equal(t, v, got.GetAttr(k))
+		}
+	}
+
+	// Exporting again gives the same document.
+	s2, err := ExportMessagesToYAML(ctx, imported)
+	require.NoError(t, err)
+	assert.Equal(t, s, s2)
+
+	// Imported messages can be tagged.
+	imported[2].Tag("agt:seen")
+	assert.True(t, imported[2].HasTag("agt:seen"))
+}
+
+func TestImportMessagesFromYAMLHandEdited(t *testing.T) {
+	doc := `
+- Role: system
+  Content: You are helpful.
+- Role: assistant
+  Content: ""
+  ToolCalls:
+  - ID: call_1
+    Name: lookup
+    Arguments: '{"q": "go"}'
+- Role: tool
+  ToolCallID: call_1
+  Content: |
+    Go is a programming language.
+  Attrs:
+    source: wiki
+    agt:cached: ""
+`
+
+	msgs, err := ImportMessagesFromYAML(doc)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+
+	// Hand written messages are assigned an ID.
+	assert.NotEmpty(t, msgs[0].ID())
+	assert.NotEqual(t, msgs[0].ID(), msgs[1].ID())
+	assert.True(t, msgs[0].CreatedAt().IsZero())
+
+	assert.Equal(t, []ToolCall{{ID: "call_1", Name: "lookup", Arguments: `{"q": "go"}`}}, msgs[1].ToolCalls)
+	assert.Equal(t, "call_1", msgs[2].ToolCallID)
+	assert.Equal(t, "wiki", msgs[2].GetAttr("source"))
+	assert.True(t, msgs[2].HasTag("agt:cached"))
+
+	content, _ := msgs[2].Content(context.Background())
+	assert.Equal(t, "Go is a programming language.\n", content)
+}
+
+func TestExportMessagesToYAMLParts(t *testing.T) {
+	ctx := context.Background()
+	m := NewPartsMessage(RoleUser,
+		TextPart("Compare"),
+		ImagePart("a.png", []byte("a")),
+		TextPart("with"),
+		FilePart("b.pdf", "application/pdf", []byte("b")),
+	)
+
+	s, err := ExportMessagesToYAML(ctx, []*Message{m})
+	require.NoError(t, err)
+	assert.Contains(t, s, "Parts:")
+
+	imported, err := ImportMessagesFromYAML(s)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	assert.Equal(t, m.parts, imported[0].parts)
+}
+
+func TestImportMessagesFromYAMLUnknownFields(t *testing.T) {
+	// Documents written before the format grew may carry fields that are no
+	// longer read; they still import.
+	doc := `- Role: system
+  Content: You are helpful.
+  Notes: written by hand
+- Role: user
+  Content: Look at this
+  Images:
+  - name: a.png
+    data: aGk=
+    format: png
+`
+
+	msgs, err := ImportMessagesFromYAML(doc)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	c, err := msgs[1].Content(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Look at this", c)
+	require.Len(t, msgs[1].Images(), 1)
+	assert.Equal(t, "a.png", msgs[1].Images()[0].Name)
+	assert.Equal(t, []byte("hi"), msgs[1].Images()[0].Data)
+}
+
+func TestImportMessagesFromYAMLInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not a list":     `Role: user`,
+		"missing role":   `- Content: hi`,
+		"wrong type":     `- {Role: user, Content: [1, 2]}`,
+		"bad image data": `- {Role: user, Images: [{name: a.png, data: "!!"}]}`,
+		"bad tool call":  `- {Role: assistant, ToolCalls: [{Arguments: "{}"}]}`,
+		"mixed parts":    `- {Role: user, Content: hi, Parts: [{type: text, text: hi}]}`,
+		"bad part type":  `- {Role: user, Parts: [{type: video}]}`,
+		"bad time":       `- {Role: user, CreatedAt: yesterday}`,
+		"bad latency":    `- {Role: assistant, Provenance: {Latency: slow}}`,
+	}
+
+	for name, doc := range tests {
+		_, err := ImportMessagesFromYAML(doc)
+		assert.Error(t, err, name)
+	}
+}
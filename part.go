@@ -0,0 +1,79 @@
+package agent
+
+import "strings"
+
+type PartType string
+
+const (
+	PartText  = PartType("text")
+	PartImage = PartType("image")
+	PartFile  = PartType("file")
+	PartAudio = PartType("audio")
+)
+
+// File is a document, such as a PDF, attached to a message.
+type File struct {
+	Name     string
+	MIMEType string
+	Data     []byte
+}
+
+// Audio is a sound clip attached to a message. Format is the encoding, such
+// as "wav" or "mp3".
+type Audio struct {
+	Format string
+	Data   []byte
+}
+
+// Part is one piece of message content. The field matching Type is set.
+type Part struct {
+	Type PartType
+
+	Text  string
+	Image *Image
+	File  *File
+	Audio *Audio
+}
+
+func TextPart(text string) Part {
+	return Part{Type: PartText, Text: text}
+}
+
+func ImagePart(name string, data []byte) Part {
+	return Part{Type: PartImage, Image: &Image{Name: name, Data: data}}
+}
+
+func FilePart(name, mimeType string, data []byte) Part {
+	return Part{Type: PartFile, File: &File{Name: name, MIMEType: mimeType, Data: data}}
+}
+
+func AudioPart(format string, data []byte) Part {
+	return Part{Type: PartAudio, Audio: &Audio{Format: format, Data: data}}
+}
+
+// joinText returns the text parts joined by newlines.
+func joinText(parts []Part) string {
+	var texts []string
+	for _, p := range parts {
+		if p.Type == PartText {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// splitSimple reports whether the parts are at most one leading text part
+// followed by images, the shape older formats could represent.
+func splitSimple(parts []Part) (text string, images []Image, ok bool) {
+	for i, p := range parts {
+		switch {
+		case p.Type == PartText && i == 0:
+			text = p.Text
+		case p.Type == PartImage && p.Image != nil:
+			images = append(images, *p.Image)
+		default:
+			return "", nil, false
+		}
+	}
+	return text, images, true
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageParts(t *testing.T) {
+	ctx := context.Background()
+
+	m := NewPartsMessage(RoleUser,
+		TextPart("Compare this image"),
+		ImagePart("a.png", []byte("a")),
+		TextPart("with this one"),
+		ImagePart("b.png", []byte("b")),
+		FilePart("spec.pdf", "application/pdf", []byte("%PDF")),
+	)
+
+	content, err := m.Content(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Compare this image\nwith this one", content)
+
+	assert.Equal(t, []Image{{Name: "a.png", Data: []byte("a")}, {Name: "b.png", Data: []byte("b")}}, m.Images())
+
+	parts, err := m.Parts(ctx)
+	require.NoError(t, err)
+	require.Len(t, parts, 5)
+	assert.Equal(t, PartText, parts[2].Type)
+	assert.Equal(t, PartFile, parts[4].Type)
+
+	// Copies do not share the part list.
+	nm := NewMessageFromMessage(m)
+	nm.AddPart(AudioPart("wav", []byte("RIFF")))
+	assert.Len(t, m.parts, 5)
+	assert.Len(t, nm.parts, 6)
+}
+
+func TestMessagePartsDynamic(t *testing.T) {
+	m := NewDynamicMessage(RoleUser, func(ctx context.Context) (string, error) {
+		return "now", nil
+	})
+	m.AddImage("a.png", []byte("a"))
+
+	parts, err := m.Parts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Part{TextPart("now"), ImagePart("a.png", []byte("a"))}, parts)
+}
+
+func TestNewContentMessageEmpty(t *testing.T) {
+	m := NewContentMessage(RoleAssistant, "")
+	parts, err := m.Parts(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, parts)
+}
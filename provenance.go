@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Usage is the number of tokens consumed by a completion.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Provenance records the provider call that produced a message.
+type Provenance struct {
+	// Provider names the provider package, such as "openai" or "ollama".
+	Provider string
+	Model    string
+
+	// ResponseID is the identifier the provider gave the response, if any.
+	ResponseID   string
+	FinishReason string
+
+	// Latency is the time taken by the provider call.
+	Latency time.Duration
+	Usage   Usage
+}
+
+// newMessageID returns a random identifier for a message.
+func newMessageID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return "msg_" + hex.EncodeToString(b)
+}
+
+// ID returns the identifier assigned to the message when it was created. It
+// is kept by copies and serialization.
+func (m *Message) ID() string {
+	return m.id
+}
+
+// CreatedAt returns when the message was created.
+func (m *Message) CreatedAt() time.Time {
+	return m.createdAt
+}
+
+// Provenance returns the record of the provider call that produced the
+// message, or nil if it wasn't produced by a provider.
+func (m *Message) Provenance() *Provenance {
+	if m.provenance == nil {
+		return nil
+	}
+	p := *m.provenance
+	return &p
+}
+
+// SetProvenance records the provider call that produced the message.
+// Providers call this on the messages they return.
+func (m *Message) SetProvenance(p Provenance) {
+	m.provenance = &p
+}
+
+type usageRecord struct {
+	PromptTokens     int `json:"prompt_tokens,omitempty" yaml:"PromptTokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty" yaml:"CompletionTokens,omitempty"`
+	TotalTokens      int `json:"total_tokens,omitempty" yaml:"TotalTokens,omitempty"`
+}
+
+// provenanceRecord is the serialized form of Provenance, shared by the JSON
+// and YAML formats.
+type provenanceRecord struct {
+	Provider     string       `json:"provider,omitempty" yaml:"Provider,omitempty"`
+	Model        string       `json:"model,omitempty" yaml:"Model,omitempty"`
+	ResponseID   string       `json:"response_id,omitempty" yaml:"ResponseID,omitempty"`
+	FinishReason string       `json:"finish_reason,omitempty" yaml:"FinishReason,omitempty"`
+	Latency      string       `json:"latency,omitempty" yaml:"Latency,omitempty"`
+	Usage        *usageRecord `json:"usage,omitempty" yaml:"Usage,omitempty"`
+}
+
+func encodeProvenance(p *Provenance) *provenanceRecord {
+	if p == nil {
+		return nil
+	}
+
+	r := &provenanceRecord{
+		Provider:     p.Provider,
+		Model:        p.Model,
+		ResponseID:   p.ResponseID,
+		FinishReason: p.FinishReason,
+	}
+	if p.Latency != 0 {
+		r.Latency = p.Latency.String()
+	}
+	if p.Usage != (Usage{}) {
+		u := usageRecord(p.Usage)
+		r.Usage = &u
+	}
+	return r
+}
+
+func decodeProvenance(r *provenanceRecord) (*Provenance, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	p := &Provenance{
+		Provider:     r.Provider,
+		Model:        r.Model,
+		ResponseID:   r.ResponseID,
+		FinishReason: r.FinishReason,
+	}
+	if r.Latency != "" {
+		d, err := time.ParseDuration(r.Latency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latency: %w", err)
+		}
+		p.Latency = d
+	}
+	if r.Usage != nil {
+		p.Usage = Usage(*r.Usage)
+	}
+	return p, nil
+}
+
+func encodeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func decodeTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// setIdentity applies serialized identity and provenance to a newly decoded
+// message. Documents without an ID keep the one generated for the message.
+func (m *Message) setIdentity(id, createdAt string, p *provenanceRecord) error {
+	if id != "" {
+		m.id = id
+	}
+
+	t, err := decodeTime(createdAt)
+	if err != nil {
+		return fmt.Errorf("invalid creation time: %w", err)
+	}
+	m.createdAt = t
+
+	m.provenance, err = decodeProvenance(p)
+	return err
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageIdentity(t *testing.T) {
+	before := time.Now()
+	a := NewContentMessage(RoleUser, "a")
+	b := NewContentMessage(RoleUser, "b")
+
+	assert.NotEmpty(t, a.ID())
+	assert.NotEqual(t, a.ID(), b.ID())
+	assert.False(t, a.CreatedAt().Before(before))
+	assert.Nil(t, a.Provenance())
+
+	a.SetProvenance(Provenance{Provider: "openai", Model: "gpt", Usage: Usage{TotalTokens: 3}})
+
+	c := NewMessageFromMessage(a)
+	assert.Equal(t, a.ID(), c.ID())
+	assert.Equal(t, a.CreatedAt(), c.CreatedAt())
+	assert.Equal(t, a.Provenance(), c.Provenance())
+
+	// The returned record is a copy.
+	c.Provenance().Model = "changed"
+	assert.Equal(t, "gpt", c.Provenance().Model)
+}
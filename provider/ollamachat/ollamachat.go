@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jmorganca/ollama/api"
 	"github.com/rhettg/agent"
@@ -221,6 +222,7 @@ func (p *provider) Completion(
 		}
 	}
 
+	st := time.Now()
 	resp, err := gc(ctx, req)
 	if err != nil {
 		return nil, err
@@ -230,7 +232,51 @@ func (p *provider) Completion(
 	// Sometimes we see a EOS token to begin the response, remove that just in case
 	cleanResp = strings.TrimPrefix(cleanResp, "</s>")
 
+	reasoning, cleanResp := splitThinking(cleanResp)
+
 	m := agent.NewContentMessage(agent.RoleAssistant, cleanResp)
+	m.SetReasoning(reasoning)
+	m.SetProvenance(responseProvenance(resp, time.Since(st)))
 
 	return m, nil
 }
+
+// splitThinking separates the <think> block reasoning models start their
+// response with from the answer. The reasoning isn't included when the dialog
+// is formatted for later requests, since only Content is.
+func splitThinking(resp string) (reasoning, answer string) {
+	rest, ok := strings.CutPrefix(resp, "<think>")
+	if !ok {
+		return "", resp
+	}
+
+	reasoning, answer, ok = strings.Cut(rest, "</think>")
+	if !ok {
+		// Generation stopped while still thinking.
+		return strings.TrimSpace(rest), ""
+	}
+
+	return strings.TrimSpace(reasoning), strings.TrimSpace(answer)
+}
+
+// responseProvenance describes the generate call that produced resp.
+func responseProvenance(resp api.GenerateResponse, latency time.Duration) agent.Provenance {
+	p := agent.Provenance{
+		Provider: "ollama",
+		Model:    resp.Model,
+		Latency:  latency,
+		Usage: agent.Usage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}
+
+	// This version of the API doesn't say why generation ended, only that
+	// it has.
+	if resp.Done {
+		p.FinishReason = "stop"
+	}
+
+	return p
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/jmorganca/ollama/api"
 	"github.com/rhettg/agent"
 	"github.com/stretchr/testify/require"
 )
@@ -67,3 +68,39 @@ func testFormatDialogMistral(t *testing.T) {
 	expected = "[INST] You are a code completion AI designed to seamlessly integrate with surrounding code.\n\nHello! [/INST] How can I help you? [INST] Will you be my friend? [/INST]"
 	require.Equal(t, expected, dialog)
 }
+
+func TestCompletionProvenance(t *testing.T) {
+	fake := func(ctx context.Context, req *api.GenerateRequest, next GenerateFunc) (api.GenerateResponse, error) {
+		return api.GenerateResponse{
+			Model:    "mistral",
+			Response: "hi",
+			Done:     true,
+			Metrics:  api.Metrics{PromptEvalCount: 12, EvalCount: 3},
+		}, nil
+	}
+
+	c := New(nil, "mistral", WithMiddleware(fake))
+	m, err := c(context.Background(), []*agent.Message{agent.NewContentMessage(agent.RoleUser, "hello")}, nil)
+	require.NoError(t, err)
+
+	p := m.Provenance()
+	require.NotNil(t, p)
+	require.Equal(t, "ollama", p.Provider)
+	require.Equal(t, "mistral", p.Model)
+	require.Equal(t, "stop", p.FinishReason)
+	require.Equal(t, agent.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}, p.Usage)
+}
+
+func TestSplitThinking(t *testing.T) {
+	reasoning, answer := splitThinking("<think>\nThe user said hello.\n</think>\n\nHello!")
+	require.Equal(t, "The user said hello.", reasoning)
+	require.Equal(t, "Hello!", answer)
+
+	reasoning, answer = splitThinking("Hello!")
+	require.Equal(t, "", reasoning)
+	require.Equal(t, "Hello!", answer)
+
+	reasoning, answer = splitThinking("<think>Still going")
+	require.Equal(t, "Still going", reasoning)
+	require.Equal(t, "", answer)
+}
@@ -3,11 +3,14 @@ package openaichat
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/openai/openai-go/v2"
 	"github.com/openai/openai-go/v2/option"
+	"github.com/openai/openai-go/v2/packages/respjson"
 	"github.com/openai/openai-go/v2/shared"
 	"github.com/rhettg/agent"
 )
@@ -24,11 +27,13 @@ type provider struct {
 	mw               []MiddlewareFunc
 	modelName        string
 	messageDeltaFunc MessageDeltaFunc
+	imagePrep        *agent.ImagePrep
 }
 
 type MessageDelta struct {
 	Role              string
 	Content           string
+	Reasoning         string
 	ToolCallID        string
 	ToolCallName      string
 	ToolCallArguments string
@@ -62,6 +67,14 @@ func WithMessageDeltaFunc(f MessageDeltaFunc) func(p *provider) {
 	}
 }
 
+// WithImagePrep prepares images before they are sent, for example scaling
+// down large photos to stay within request limits. See agent.PrepareImage.
+func WithImagePrep(prep agent.ImagePrep) func(p *provider) {
+	return func(p *provider) {
+		p.imagePrep = &prep
+	}
+}
+
 func New(apiKey string, modelName string, opts ...Option) agent.CompletionFunc {
 	client := openai.NewClient(option.WithAPIKey(apiKey))
 	return NewWithClient(client, modelName, opts...)
@@ -82,6 +95,11 @@ func NewWithClient(client openai.Client, modelName string, opts ...Option) agent
 }
 
 func (p *provider) stream(ctx context.Context, params openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	// Usage is only reported for streams when asked for.
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
 	stream := p.client.Chat.Completions.NewStreaming(ctx, params, opts...)
 	defer stream.Close()
 
@@ -105,6 +123,7 @@ func (p *provider) stream(ctx context.Context, params openai.ChatCompletionNewPa
 
 	// Use strings.Builder for efficient string concatenation
 	var contentBuilder strings.Builder
+	var reasoningBuilder strings.Builder
 	toolCallArgBuilders := make(map[int]*strings.Builder)
 
 	for stream.Next() {
@@ -117,12 +136,26 @@ func (p *provider) stream(ctx context.Context, params openai.ChatCompletionNewPa
 		if result.ID == "" {
 			result.ID = evt.ID
 			result.Created = evt.Created
+			if evt.Model != "" {
+				result.Model = evt.Model
+			}
 		}
 
 		if len(evt.Choices) > 0 {
 			delta := evt.Choices[0].Delta
 			choice := &result.Choices[0]
 
+			// Accumulate reasoning, from servers that stream it
+			if r := reasoningField(delta.JSON.ExtraFields); r != "" {
+				if p.messageDeltaFunc != nil {
+					p.messageDeltaFunc(ctx, MessageDelta{
+						Role:      delta.Role,
+						Reasoning: r,
+					})
+				}
+				reasoningBuilder.WriteString(r)
+			}
+
 			// Accumulate content
 			if delta.Content != "" {
 				if p.messageDeltaFunc != nil {
@@ -196,6 +229,15 @@ func (p *provider) stream(ctx context.Context, params openai.ChatCompletionNewPa
 
 	// Set final content from builder
 	result.Choices[0].Message.Content = contentBuilder.String()
+	if reasoningBuilder.Len() > 0 {
+		raw, err := json.Marshal(reasoningBuilder.String())
+		if err != nil {
+			return nil, err
+		}
+		result.Choices[0].Message.JSON.ExtraFields = map[string]respjson.Field{
+			"reasoning_content": respjson.NewField(string(raw)),
+		}
+	}
 
 	// Set final tool call arguments from builders
 	for i, tc := range result.Choices[0].Message.ToolCalls {
@@ -210,57 +252,9 @@ func (p *provider) stream(ctx context.Context, params openai.ChatCompletionNewPa
 func (p *provider) Completion(
 	ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef,
 ) (*agent.Message, error) {
-	pMsgs := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
-	for _, m := range msgs {
-		c, err := m.Content(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get message content: %w", err)
-		}
-
-		switch m.Role {
-		case agent.RoleSystem:
-			pMsgs = append(pMsgs, openai.SystemMessage(c))
-		case agent.RoleUser:
-			if len(m.Images()) > 0 {
-				// Handle multimodal content
-				content := make([]openai.ChatCompletionContentPartUnionParam, 0)
-				if c != "" {
-					content = append(content, openai.TextContentPart(c))
-				}
-
-				for _, img := range m.Images() {
-					mimeType := mimeType(img.Name)
-					imageURL := encodeImageURL(mimeType, img.Data)
-					content = append(content, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
-						URL: imageURL,
-					}))
-				}
-
-				pMsgs = append(pMsgs, openai.UserMessage(content))
-			} else {
-				pMsgs = append(pMsgs, openai.UserMessage(c))
-			}
-		case agent.RoleAssistant:
-			aMsg := openai.AssistantMessage(c)
-			aMsg.OfAssistant.ToolCalls = make([]openai.ChatCompletionMessageToolCallUnionParam, len(m.ToolCalls))
-			for i, tc := range m.ToolCalls {
-				aMsg.OfAssistant.ToolCalls[i] = openai.ChatCompletionMessageToolCallUnionParam{
-					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
-						ID: tc.ID,
-						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
-							Name:      tc.Name,
-							Arguments: tc.Arguments,
-						},
-						Type: "function",
-					},
-				}
-			}
-			pMsgs = append(pMsgs, aMsg)
-		case agent.RoleTool:
-			// For tool responses, we need the tool call ID
-			toolID := m.ToolCallID
-			pMsgs = append(pMsgs, openai.ToolMessage(c, toolID))
-		}
+	pMsgs, err := p.convertMessages(ctx, msgs)
+	if err != nil {
+		return nil, err
 	}
 
 	tools := make([]openai.ChatCompletionToolUnionParam, 0, len(tdfs))
@@ -303,6 +297,7 @@ func (p *provider) Completion(
 		}
 	}
 
+	st := time.Now()
 	resp, err := c(ctx, params)
 	if err != nil {
 		return nil, err
@@ -314,6 +309,19 @@ func (p *provider) Completion(
 
 	rMsg := resp.Choices[0].Message
 	m := agent.NewContentMessage(agent.Role(rMsg.Role), rMsg.Content)
+	m.SetReasoning(reasoningField(rMsg.JSON.ExtraFields))
+	m.SetProvenance(agent.Provenance{
+		Provider:     "openai",
+		Model:        resp.Model,
+		ResponseID:   resp.ID,
+		FinishReason: string(resp.Choices[0].FinishReason),
+		Latency:      time.Since(st),
+		Usage: agent.Usage{
+			PromptTokens:     int(resp.Usage.PromptTokens),
+			CompletionTokens: int(resp.Usage.CompletionTokens),
+			TotalTokens:      int(resp.Usage.TotalTokens),
+		},
+	})
 
 	if len(rMsg.ToolCalls) > 0 {
 		// Populate the new ToolCalls field with all tool calls
@@ -330,17 +338,182 @@ func (p *provider) Completion(
 	return m, nil
 }
 
-func mimeType(name string) string {
-	dot := strings.LastIndex(name, ".")
-	if dot == -1 || dot == len(name)-1 {
-		// Just a guess
-		return "image/jpeg"
+// reasoningFields are the response fields OpenAI compatible servers use for
+// reasoning, since the OpenAI API itself doesn't return it.
+var reasoningFields = []string{"reasoning_content", "reasoning"}
+
+// reasoningField returns the reasoning from a message or delta, if any.
+func reasoningField(fields map[string]respjson.Field) string {
+	for _, name := range reasoningFields {
+		// Extra fields are never marked valid, so check the raw value.
+		f, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		var r string
+		if err := json.Unmarshal([]byte(f.Raw()), &r); err == nil && r != "" {
+			return r
+		}
+	}
+	return ""
+}
+
+// convertMessages translates the dialog into request messages.
+//
+// Reasoning is never sent back; servers that return it reject or ignore it
+// in requests.
+//
+// Tool messages can only hold text, so images and other attachments returned
+// by tools are sent in a user message following the tool messages for that
+// turn.
+func (p *provider) convertMessages(ctx context.Context, msgs []*agent.Message) ([]openai.ChatCompletionMessageParamUnion, error) {
+	pMsgs := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
+
+	var toolAttachments []openai.ChatCompletionContentPartUnionParam
+	for i, m := range msgs {
+		parts, err := m.Parts(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message content: %w", err)
+		}
+		c, err := m.Content(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message content: %w", err)
+		}
+
+		switch m.Role {
+		case agent.RoleSystem:
+			pMsgs = append(pMsgs, openai.SystemMessage(c))
+		case agent.RoleUser:
+			if len(parts) > 1 || (len(parts) == 1 && parts[0].Type != agent.PartText) {
+				// Handle multimodal content
+				content, err := p.contentParts(ctx, parts)
+				if err != nil {
+					return nil, err
+				}
+				pMsgs = append(pMsgs, openai.UserMessage(content))
+			} else {
+				pMsgs = append(pMsgs, openai.UserMessage(c))
+			}
+		case agent.RoleAssistant:
+			aMsg := openai.AssistantMessage(c)
+			aMsg.OfAssistant.ToolCalls = make([]openai.ChatCompletionMessageToolCallUnionParam, len(m.ToolCalls))
+			for i, tc := range m.ToolCalls {
+				aMsg.OfAssistant.ToolCalls[i] = openai.ChatCompletionMessageToolCallUnionParam{
+					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID: tc.ID,
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+							Name:      tc.Name,
+							Arguments: tc.Arguments,
+						},
+						Type: "function",
+					},
+				}
+			}
+			pMsgs = append(pMsgs, aMsg)
+		case agent.RoleTool:
+			// For tool responses, we need the tool call ID
+			toolID := m.ToolCallID
+			pMsgs = append(pMsgs, openai.ToolMessage(c, toolID))
+
+			var attachments []agent.Part
+			for _, part := range parts {
+				if part.Type != agent.PartText {
+					attachments = append(attachments, part)
+				}
+			}
+			if len(attachments) > 0 {
+				content, err := p.contentParts(ctx, attachments)
+				if err != nil {
+					return nil, err
+				}
+				toolAttachments = append(toolAttachments, openai.TextContentPart(fmt.Sprintf("%s returned by tool call %s:", attachmentLabel(attachments), toolID)))
+				toolAttachments = append(toolAttachments, content...)
+			}
+
+			// Every tool message must directly follow the assistant message,
+			// so wait for the last one.
+			last := i == len(msgs)-1 || msgs[i+1].Role != agent.RoleTool
+			if last && len(toolAttachments) > 0 {
+				pMsgs = append(pMsgs, openai.UserMessage(toolAttachments))
+				toolAttachments = nil
+			}
+		}
+	}
+
+	return pMsgs, nil
+}
+
+// attachmentLabel describes the attachments as "Images" when they are all
+// images, and "Attachments" otherwise.
+func attachmentLabel(parts []agent.Part) string {
+	for _, p := range parts {
+		if p.Type != agent.PartImage {
+			return "Attachments"
+		}
+	}
+	return "Images"
+}
+
+// contentParts translates message parts into request content parts,
+// preserving their order.
+func (p *provider) contentParts(ctx context.Context, parts []agent.Part) ([]openai.ChatCompletionContentPartUnionParam, error) {
+	content := make([]openai.ChatCompletionContentPartUnionParam, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == agent.PartText && part.Text != "":
+			content = append(content, openai.TextContentPart(part.Text))
+		case part.Type == agent.PartImage && part.Image != nil:
+			img, err := p.imagePart(ctx, *part.Image)
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, img)
+		case part.Type == agent.PartFile && part.File != nil:
+			content = append(content, openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+				FileData: openai.String(encodeDataURL(part.File.MIMEType, part.File.Data)),
+				Filename: openai.String(part.File.Name),
+			}))
+		case part.Type == agent.PartAudio && part.Audio != nil:
+			content = append(content, openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+				Data:   base64.StdEncoding.EncodeToString(part.Audio.Data),
+				Format: part.Audio.Format,
+			}))
+		}
+	}
+	return content, nil
+}
+
+// imagePart sends images referenced by URL as is, and others inline as a
+// data URL.
+func (p *provider) imagePart(ctx context.Context, img agent.Image) (openai.ChatCompletionContentPartUnionParam, error) {
+	var err error
+	if p.imagePrep != nil {
+		img, err = agent.PrepareImage(ctx, img, *p.imagePrep)
+	} else {
+		img, err = img.Resolve(ctx)
+	}
+	if err != nil {
+		return openai.ChatCompletionContentPartUnionParam{}, err
+	}
+
+	imageURL := img.URL
+	if len(img.Data) > 0 || imageURL == "" {
+		mimeType := img.ContentType()
+		if mimeType == "" {
+			// Just a guess
+			mimeType = "image/jpeg"
+		}
+		imageURL = encodeDataURL(mimeType, img.Data)
 	}
 
-	return "image/" + strings.ToLower(name[dot+1:])
+	return openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+		URL:    imageURL,
+		Detail: img.Detail,
+	}), nil
 }
 
-func encodeImageURL(mimeType string, data []byte) string {
+func encodeDataURL(mimeType string, data []byte) string {
 	// Based on the python reference code in
 	// https://platform.openai.com/docs/guides/vision/uploading-base-64-encoded-images
 	// this should be the parallel of:
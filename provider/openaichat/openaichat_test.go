@@ -0,0 +1,201 @@
+package openaichat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roles(t *testing.T, pMsgs []openai.ChatCompletionMessageParamUnion) []string {
+	roles := make([]string, len(pMsgs))
+	for i, m := range pMsgs {
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+
+		var v struct {
+			Role string `json:"role"`
+		}
+		require.NoError(t, json.Unmarshal(data, &v))
+		roles[i] = v.Role
+	}
+	return roles
+}
+
+func TestConvertMessagesToolImages(t *testing.T) {
+	assistant := agent.NewContentMessage(agent.RoleAssistant, "")
+	assistant.ToolCalls = []agent.ToolCall{
+		{ID: "call_1", Name: "chart", Arguments: "{}"},
+		{ID: "call_2", Name: "chart", Arguments: "{}"},
+	}
+
+	chart := agent.NewContentMessage(agent.RoleTool, "A chart")
+	chart.ToolCallID = "call_1"
+	chart.AddImage("chart.png", []byte("png"))
+
+	plain := agent.NewContentMessage(agent.RoleTool, "No chart")
+	plain.ToolCallID = "call_2"
+
+	msgs := []*agent.Message{
+		agent.NewContentMessage(agent.RoleUser, "Draw charts"),
+		assistant,
+		chart,
+		plain,
+	}
+
+	pMsgs, err := (&provider{}).convertMessages(context.Background(), msgs)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"user", "assistant", "tool", "tool", "user"}, roles(t, pMsgs))
+
+	data, err := json.Marshal(pMsgs[4])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"role": "user",
+		"content": [
+			{"type": "text", "text": "Images returned by tool call call_1:"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,cG5n"}}
+		]
+	}`, string(data))
+}
+
+func TestConvertMessagesNoToolImages(t *testing.T) {
+	tm := agent.NewContentMessage(agent.RoleTool, "result")
+	tm.ToolCallID = "call_1"
+
+	pMsgs, err := (&provider{}).convertMessages(context.Background(), []*agent.Message{tm, agent.NewContentMessage(agent.RoleUser, "thanks")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tool", "user"}, roles(t, pMsgs))
+}
+
+func TestConvertMessagesParts(t *testing.T) {
+	m := agent.NewPartsMessage(agent.RoleUser,
+		agent.TextPart("Compare this image"),
+		agent.ImagePart("a.png", []byte("a")),
+		agent.TextPart("with this document"),
+		agent.FilePart("b.pdf", "application/pdf", []byte("b")),
+		agent.AudioPart("wav", []byte("c")),
+	)
+
+	pMsgs, err := (&provider{}).convertMessages(context.Background(), []*agent.Message{m})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(pMsgs[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"role": "user",
+		"content": [
+			{"type": "text", "text": "Compare this image"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,YQ=="}},
+			{"type": "text", "text": "with this document"},
+			{"type": "file", "file": {"file_data": "data:application/pdf;base64,Yg==", "filename": "b.pdf"}},
+			{"type": "input_audio", "input_audio": {"data": "Yw==", "format": "wav"}}
+		]
+	}`, string(data))
+}
+
+func TestConvertMessagesImageURL(t *testing.T) {
+	m := agent.NewContentMessage(agent.RoleUser, "What is this?")
+	m.AddPart(agent.ImagePartFrom(agent.Image{URL: "https://example.com/cat.png", Detail: "low"}))
+
+	p := &provider{imagePrep: &agent.ImagePrep{Detail: "high"}}
+	pMsgs, err := p.convertMessages(context.Background(), []*agent.Message{m})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(pMsgs[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"role": "user",
+		"content": [
+			{"type": "text", "text": "What is this?"},
+			{"type": "image_url", "image_url": {"url": "https://example.com/cat.png", "detail": "low"}}
+		]
+	}`, string(data))
+}
+
+func TestConvertMessagesImageContentType(t *testing.T) {
+	// PNG data with a misleading name.
+	png := []byte("\x89PNG\r\n\x1a\n")
+	m := agent.NewImageMessage(agent.RoleUser, "", "photo.jpg", png)
+
+	pMsgs, err := (&provider{}).convertMessages(context.Background(), []*agent.Message{m})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(pMsgs[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "data:image/png;base64,")
+}
+
+func TestCompletionProvenance(t *testing.T) {
+	fake := func(ctx context.Context, params openai.ChatCompletionNewParams, next CreateCompletionFn) (*openai.ChatCompletion, error) {
+		return &openai.ChatCompletion{
+			ID:    "chatcmpl-1",
+			Model: "gpt-test-0001",
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: "assistant", Content: "hi"},
+				FinishReason: "stop",
+			}},
+			Usage: openai.CompletionUsage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9},
+		}, nil
+	}
+
+	c := NewWithClient(openai.NewClient(), "gpt-test", WithMiddleware(fake))
+	m, err := c(context.Background(), []*agent.Message{agent.NewContentMessage(agent.RoleUser, "hello")}, nil)
+	require.NoError(t, err)
+
+	p := m.Provenance()
+	require.NotNil(t, p)
+	assert.Equal(t, "openai", p.Provider)
+	assert.Equal(t, "gpt-test-0001", p.Model)
+	assert.Equal(t, "chatcmpl-1", p.ResponseID)
+	assert.Equal(t, "stop", p.FinishReason)
+	assert.Equal(t, agent.Usage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}, p.Usage)
+	assert.NotEmpty(t, m.ID())
+}
+
+func TestCompletionReasoning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"id":"c1","model":"r1","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Think "}}]}`,
+			`{"id":"c1","model":"r1","choices":[{"index":0,"delta":{"reasoning_content":"hard."}}]}`,
+			`{"id":"c1","model":"r1","choices":[{"index":0,"delta":{"content":"42"},"finish_reason":"stop"}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var deltas []MessageDelta
+	client := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"))
+	c := NewWithClient(client, "r1", WithMessageDeltaFunc(func(ctx context.Context, d MessageDelta) {
+		deltas = append(deltas, d)
+	}))
+
+	m, err := c(context.Background(), []*agent.Message{agent.NewContentMessage(agent.RoleUser, "question")}, nil)
+	require.NoError(t, err)
+
+	content, _ := m.Content(context.Background())
+	assert.Equal(t, "42", content)
+	assert.Equal(t, "Think hard.", m.Reasoning())
+
+	require.Len(t, deltas, 3)
+	assert.Equal(t, "Think ", deltas[0].Reasoning)
+	assert.Equal(t, "42", deltas[2].Content)
+
+	// Reasoning isn't sent back.
+	pMsgs, err := (&provider{}).convertMessages(context.Background(), []*agent.Message{m})
+	require.NoError(t, err)
+	data, err := json.Marshal(pMsgs)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "Think")
+}
@@ -23,10 +23,11 @@ func isRetry(err error) bool {
 }
 
 func Retry(limit int) MiddlewareFunc {
-
-	backoff := 100 * time.Millisecond
-
 	return func(ctx context.Context, params openai.ChatCompletionNewParams, next CreateCompletionFn) (*openai.ChatCompletion, error) {
+		// Each call backs off on its own, so concurrent calls don't share
+		// state.
+		backoff := 100 * time.Millisecond
+
 		for try := 0; try < limit; try++ {
 			resp, err := next(ctx, params)
 			if err != nil {
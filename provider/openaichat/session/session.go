@@ -7,13 +7,18 @@ import (
 	"log/slog"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/openai/openai-go/v2"
 	"github.com/rhettg/agent/provider/openaichat"
 )
 
+// SessionStore saves each request and response to a directory. It may be
+// shared by agents running concurrently.
 type SessionStore struct {
+	// mu serializes saves so each gets a distinct file number.
+	mu          sync.Mutex
 	sessionPath string
 }
 
@@ -60,6 +65,9 @@ func (s *SessionStore) initPath() error {
 }
 
 func (s *SessionStore) SaveParams(p openai.ChatCompletionNewParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	err := s.initPath()
 	if err != nil {
 		return fmt.Errorf("failed to initialize data directory: %w", err)
@@ -85,6 +93,9 @@ func (s *SessionStore) SaveParams(p openai.ChatCompletionNewParams) error {
 }
 
 func (s *SessionStore) SaveResponse(r *openai.ChatCompletion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	err := s.initPath()
 	if err != nil {
 		return fmt.Errorf("failed to initialize data directory: %w", err)
@@ -110,6 +121,9 @@ func (s *SessionStore) SaveResponse(r *openai.ChatCompletion) error {
 }
 
 func (s *SessionStore) SaveError(responseErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	err := s.initPath()
 	if err != nil {
 		return fmt.Errorf("failed to initialize data directory: %w", err)
@@ -2,11 +2,12 @@ package openaichat
 
 import (
 	"context"
+	"sync"
 
 	"github.com/openai/openai-go/v2"
 )
 
-type Usage struct {
+type UsageTotals struct {
 	Completions      int
 	CompletionTokens int
 	PromptTokens     int
@@ -14,10 +15,30 @@ type Usage struct {
 	Errors           int
 }
 
+// Usage is middleware that totals the tokens used by completions. It may be
+// shared by agents running concurrently; use Totals to read the counts while
+// completions are in flight.
+type Usage struct {
+	mu sync.Mutex
+	UsageTotals
+}
+
+// Totals returns a snapshot of the counts.
+func (u *Usage) Totals() UsageTotals {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.UsageTotals
+}
+
 func (u *Usage) Middleware(
 	ctx context.Context, params openai.ChatCompletionNewParams, next CreateCompletionFn,
 ) (*openai.ChatCompletion, error) {
 	resp, err := next(ctx, params)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
 	if err != nil {
 		u.Errors++
 		return resp, err
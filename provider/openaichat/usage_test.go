@@ -0,0 +1,46 @@
+package openaichat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageConcurrent(t *testing.T) {
+	var u Usage
+
+	next := func(ctx context.Context, params openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+		if params.Model == "fail" {
+			return nil, errors.New("failed")
+		}
+		return &openai.ChatCompletion{Usage: openai.CompletionUsage{PromptTokens: 2, CompletionTokens: 1, TotalTokens: 3}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			params := openai.ChatCompletionNewParams{Model: "ok"}
+			if i%4 == 0 {
+				params.Model = "fail"
+			}
+			_, _ = u.Middleware(context.Background(), params, next)
+			_ = u.Totals()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, UsageTotals{
+		Completions:      15,
+		CompletionTokens: 15,
+		PromptTokens:     30,
+		TotalTokens:      45,
+		Errors:           5,
+	}, u.Totals())
+}
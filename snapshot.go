@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SnapshotVersion is the version of the snapshot document. Snapshots with a
+// newer version are rejected.
+const SnapshotVersion = 1
+
+// Snapshot is the saved state of an agent: its messages and the state of any
+// middleware registered with WithSnapshotter.
+type Snapshot struct {
+	Version    int                        `json:"version"`
+	Messages   []*Message                 `json:"messages"`
+	Middleware map[string]json.RawMessage `json:"middleware,omitempty"`
+}
+
+// Snapshotter is implemented by middleware with state that should be saved
+// along with the agent.
+type Snapshotter interface {
+	// Snapshot returns the state encoded as JSON.
+	Snapshot(ctx context.Context) (json.RawMessage, error)
+
+	// Restore replaces the state with one returned by Snapshot.
+	Restore(ctx context.Context, data json.RawMessage) error
+}
+
+type namedSnapshotter struct {
+	name string
+	s    Snapshotter
+}
+
+// WithSnapshotter includes the state of s, under name, in the agent's
+// snapshots. If the name is already registered, a numeric suffix is added,
+// so agents built with the same options use the same names.
+func WithSnapshotter(name string, s Snapshotter) Option {
+	return func(a *Agent) {
+		unique := name
+		for n := 2; a.hasSnapshotter(unique); n++ {
+			unique = fmt.Sprintf("%s-%d", name, n)
+		}
+		a.snapshotters = append(a.snapshotters, namedSnapshotter{name: unique, s: s})
+	}
+}
+
+func (a *Agent) hasSnapshotter(name string) bool {
+	for _, ns := range a.snapshotters {
+		if ns.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type autoSave struct {
+	store Store
+	key   string
+}
+
+// WithAutoSave saves a snapshot of the agent to store after every step. If
+// saving fails, Step returns the new message along with the error.
+func WithAutoSave(store Store, key string) Option {
+	return func(a *Agent) {
+		a.autoSave = &autoSave{store: store, key: key}
+	}
+}
+
+// Snapshot captures the state of the agent. Dynamic message content is
+// resolved. It waits for a running step to finish.
+//
+// Checkpoints and branches are not included.
+func (a *Agent) Snapshot(ctx context.Context) (*Snapshot, error) {
+	a.stepMu.Lock()
+	defer a.stepMu.Unlock()
+
+	return a.snapshotState(ctx)
+}
+
+func (a *Agent) snapshotState(ctx context.Context) (*Snapshot, error) {
+	msgs := a.Messages()
+
+	s := &Snapshot{
+		Version:  SnapshotVersion,
+		Messages: make([]*Message, len(msgs)),
+	}
+
+	for i, m := range msgs {
+		rm, err := resolveContent(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		s.Messages[i] = rm
+	}
+
+	for _, ns := range a.snapshotters {
+		data, err := ns.s.Snapshot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error taking snapshot of %s: %w", ns.name, err)
+		}
+
+		if s.Middleware == nil {
+			s.Middleware = make(map[string]json.RawMessage)
+		}
+		s.Middleware[ns.name] = data
+	}
+
+	return s, nil
+}
+
+// Restore replaces the agent's messages and middleware state with those in
+// the snapshot, and removes its checkpoints. Middleware missing from the
+// snapshot is left as is. It waits for a running step to finish. Observers
+// are told the current messages were removed and the restored ones added.
+func (a *Agent) Restore(ctx context.Context, s *Snapshot) error {
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+
+	a.stepMu.Lock()
+	for _, ns := range a.snapshotters {
+		data, ok := s.Middleware[ns.name]
+		if !ok {
+			continue
+		}
+
+		if err := ns.s.Restore(ctx, data); err != nil {
+			a.stepMu.Unlock()
+			return fmt.Errorf("error restoring %s: %w", ns.name, err)
+		}
+	}
+
+	msgs := make([]*Message, len(s.Messages))
+	copy(msgs, s.Messages)
+
+	a.mu.Lock()
+	prev := a.messages
+	a.messages = msgs
+	a.checkpoints = make(map[string][]*Message)
+	a.mu.Unlock()
+	a.stepMu.Unlock()
+
+	a.emitReplaced(ctx, prev, msgs)
+	return nil
+}
+
+// Save stores a snapshot of the agent under key.
+func (a *Agent) Save(ctx context.Context, store Store, key string) error {
+	s, err := a.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	return store.Save(ctx, key, s)
+}
+
+// Load restores the agent from the snapshot stored under key.
+func (a *Agent) Load(ctx context.Context, store Store, key string) error {
+	s, err := store.Load(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return a.Restore(ctx, s)
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter is middleware counting the steps it has seen.
+type counter struct {
+	n int
+}
+
+func (c *counter) middleware(next CompletionFunc) CompletionFunc {
+	return func(ctx context.Context, msgs []*Message, tdfs []ToolDef) (*Message, error) {
+		c.n++
+		return next(ctx, msgs, tdfs)
+	}
+}
+
+func (c *counter) Snapshot(ctx context.Context) (json.RawMessage, error) {
+	return json.Marshal(c.n)
+}
+
+func (c *counter) Restore(ctx context.Context, data json.RawMessage) error {
+	return json.Unmarshal(data, &c.n)
+}
+
+func newCountingAgent(c *counter, opts ...Option) *Agent {
+	opts = append([]Option{WithMiddleware(c.middleware), WithSnapshotter("counter", c)}, opts...)
+	return New(countingCompletion, opts...)
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := &counter{}
+			a := newCountingAgent(c)
+			a.Add(RoleUser, "hello")
+			_, err := a.Step(ctx)
+			require.NoError(t, err)
+
+			require.NoError(t, a.Save(ctx, store, "session-1"))
+
+			// Later changes don't affect the saved snapshot.
+			a.Add(RoleUser, "more")
+
+			rc := &counter{}
+			ra := newCountingAgent(rc)
+			require.NoError(t, ra.Load(ctx, store, "session-1"))
+
+			assert.Equal(t, []string{"hello", "reply to 1"}, contents(t, ra))
+			assert.Equal(t, a.Messages()[1].ID(), ra.Messages()[1].ID())
+			assert.Equal(t, 1, rc.n)
+
+			_, err = ra.Step(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, rc.n)
+
+			err = ra.Load(ctx, store, "missing")
+			assert.ErrorIs(t, err, ErrSnapshotNotFound)
+		})
+	}
+}
+
+func TestAutoSave(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	a := newCountingAgent(&counter{}, WithAutoSave(store, "auto"))
+	a.Add(RoleUser, "hello")
+	_, err := a.Step(ctx)
+	require.NoError(t, err)
+
+	s, err := store.Load(ctx, "auto")
+	require.NoError(t, err)
+	assert.Len(t, s.Messages, 2)
+	assert.JSONEq(t, `1`, string(s.Middleware["counter"]))
+}
+
+type failingStore struct{}
+
+func (failingStore) Save(ctx context.Context, key string, s *Snapshot) error {
+	return errors.New("disk full")
+}
+
+func (failingStore) Load(ctx context.Context, key string) (*Snapshot, error) {
+	return nil, ErrSnapshotNotFound
+}
+
+func TestAutoSaveError(t *testing.T) {
+	a := New(countingCompletion, WithAutoSave(failingStore{}, "auto"))
+	a.Add(RoleUser, "hello")
+
+	m, err := a.Step(context.Background())
+	assert.EqualError(t, err, "error saving agent: disk full")
+	assert.NotNil(t, m)
+	assert.Len(t, a.Messages(), 2)
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	c := &counter{n: 5}
+	a := newCountingAgent(c, WithSnapshotter("counter", &counter{}))
+	a.Add(RoleUser, "hello")
+	a.Checkpoint("start")
+
+	// Middleware missing from the snapshot keeps its state.
+	require.NoError(t, a.Restore(ctx, &Snapshot{Version: SnapshotVersion}))
+	assert.Empty(t, a.Messages())
+	assert.Empty(t, a.Checkpoints())
+	assert.Equal(t, 5, c.n)
+
+	err := a.Restore(ctx, &Snapshot{Version: 99})
+	assert.EqualError(t, err, "unsupported snapshot version 99")
+
+	// Names registered twice are made unique.
+	s, err := a.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, s.Middleware, "counter")
+	assert.Contains(t, s.Middleware, "counter-2")
+}
+
+func TestFileStoreKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir())
+
+	for _, key := range []string{"", "../escape", "a/b", ".hidden"} {
+		assert.Error(t, store.Save(ctx, key, &Snapshot{Version: SnapshotVersion}), key)
+	}
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrSnapshotNotFound is returned by a Store when there is no snapshot with
+// the given key.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Store saves and loads agent snapshots by key.
+type Store interface {
+	Save(ctx context.Context, key string, s *Snapshot) error
+	Load(ctx context.Context, key string) (*Snapshot, error)
+}
+
+func encodeSnapshot(s *Snapshot) ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding snapshot: %w", err)
+	}
+	return data, nil
+}
+
+func decodeSnapshot(data []byte) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %w", err)
+	}
+
+	for i, m := range s.Messages {
+		if m == nil {
+			return nil, fmt.Errorf("message %d is null", i)
+		}
+	}
+
+	return &s, nil
+}
+
+// MemoryStore keeps snapshots in memory. Snapshots are encoded when saved,
+// so later changes to the agent don't affect them.
+type MemoryStore struct {
+	mu        sync.Mutex
+	snapshots map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{snapshots: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, key string, snap *Snapshot) error {
+	data, err := encodeSnapshot(snap)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshots[key] = data
+	return nil
+}
+
+func (s *MemoryStore) Load(ctx context.Context, key string) (*Snapshot, error) {
+	s.mu.Lock()
+	data, ok := s.snapshots[key]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, key)
+	}
+
+	return decodeSnapshot(data)
+}
+
+// FileStore keeps each snapshot in a JSON file named after its key.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore stores snapshots in dir, which is created when needed.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid snapshot key %q", key)
+	}
+	return filepath.Join(s.dir, key+".json"), nil
+}
+
+// Save writes the snapshot to a temporary file and renames it into place, so
+// an interrupted save leaves the previous snapshot intact.
+func (s *FileStore) Save(ctx context.Context, key string, snap *Snapshot) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeSnapshot(snap)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating snapshot directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(s.dir, "."+key+"-*")
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Load(ctx context.Context, key string) (*Snapshot, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading snapshot: %w", err)
+	}
+
+	return decodeSnapshot(data)
+}
@@ -38,7 +38,7 @@ func EstimateTokens(ctx context.Context, t tokenizer.Codec, m *Message) (int, er
 		}
 	}
 
-	data, err := json.Marshal(m)
+	data, err := json.Marshal(cm)
 	if err != nil {
 		return 0, err
 	}
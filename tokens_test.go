@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	ctx := context.Background()
+
+	codec, err := tokenizer.Get(tokenizer.Cl100kBase)
+	require.NoError(t, err)
+
+	short, err := EstimateTokens(ctx, codec, NewContentMessage(RoleUser, "hello"))
+	require.NoError(t, err)
+
+	long, err := EstimateTokens(ctx, codec, NewContentMessage(RoleUser, strings.Repeat("hello ", 100)))
+	require.NoError(t, err)
+
+	// Content must be counted, not just the message envelope.
+	assert.Greater(t, long, short+90)
+}
@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/rhettg/agent"
+)
+
+// DeniedTag is set on tool messages for calls that were denied by an Approver.
+const DeniedTag = "tools:denied"
+
+// EditedArgumentsAttr is set on tool messages for calls whose arguments were
+// edited by an Approver. It contains the arguments the tool was called with.
+const EditedArgumentsAttr = "tools:edited_arguments"
+
+type Verdict int
+
+const (
+	VerdictAllow Verdict = iota
+	VerdictDeny
+	VerdictEdit
+)
+
+// Decision is the result of asking an Approver about a tool call.
+type Decision struct {
+	Verdict Verdict
+
+	// Reason is sent to the model when a call is denied.
+	Reason string
+
+	// Arguments replace the arguments of the call when it is edited.
+	Arguments string
+}
+
+// Allow approves a tool call as is.
+func Allow() Decision {
+	return Decision{Verdict: VerdictAllow}
+}
+
+// Deny rejects a tool call. The reason is reported to the model.
+func Deny(reason string) Decision {
+	return Decision{Verdict: VerdictDeny, Reason: reason}
+}
+
+// EditArguments approves a tool call with replacement arguments.
+func EditArguments(arguments string) Decision {
+	return Decision{Verdict: VerdictEdit, Arguments: arguments}
+}
+
+// Approver decides whether a tool call may run. An error aborts the step.
+type Approver interface {
+	Approve(ctx context.Context, call agent.ToolCall) (Decision, error)
+}
+
+type ApproverFunc func(ctx context.Context, call agent.ToolCall) (Decision, error)
+
+func (f ApproverFunc) Approve(ctx context.Context, call agent.ToolCall) (Decision, error) {
+	return f(ctx, call)
+}
+
+// WithApprover requires every tool call to be approved before it runs.
+func WithApprover(a Approver) Option {
+	return func(f *Tools) {
+		f.approver = a
+	}
+}
+
+// approve asks the approver about the tool call. It returns the call to
+// execute, or a message to return in its place if the call was denied.
+func (f *Tools) approve(ctx context.Context, toolCall *agent.ToolCall) (*agent.ToolCall, *agent.Message, error) {
+	d, err := f.approver.Approve(ctx, *toolCall)
+	if err != nil {
+		return nil, nil, fmt.Errorf("approval failed: %w", err)
+	}
+
+	switch d.Verdict {
+	case VerdictAllow:
+		return toolCall, nil, nil
+	case VerdictEdit:
+		tc := *toolCall
+		tc.Arguments = d.Arguments
+		return &tc, nil, nil
+	default:
+		content := "tool call denied"
+		if d.Reason != "" {
+			content = fmt.Sprintf("tool call denied: %s", d.Reason)
+		}
+
+		m := agent.NewContentMessage(agent.RoleTool, content)
+		m.ToolCallID = toolCall.ID
+		m.Tag(DeniedTag)
+		return nil, m, nil
+	}
+}
+
+// TerminalApprover prompts for approval of each tool call on a terminal.
+type TerminalApprover struct {
+	mu  sync.Mutex
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func NewTerminalApprover(in io.Reader, out io.Writer) *TerminalApprover {
+	return &TerminalApprover{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+func (t *TerminalApprover) readLine() (string, error) {
+	line, err := t.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (t *TerminalApprover) Approve(ctx context.Context, call agent.ToolCall) (Decision, error) {
+	// Parallel tool calls must not interleave their prompts.
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		fmt.Fprintf(t.out, "Run tool %s(%s)? [y]es, [n]o, [e]dit: ", call.Name, call.Arguments)
+
+		answer, err := t.readLine()
+		if err != nil {
+			return Decision{}, err
+		}
+
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return Allow(), nil
+		case "n", "no":
+			fmt.Fprint(t.out, "Reason: ")
+			reason, err := t.readLine()
+			if err != nil {
+				return Decision{}, err
+			}
+			return Deny(reason), nil
+		case "e", "edit":
+			fmt.Fprint(t.out, "Arguments: ")
+			args, err := t.readLine()
+			if err != nil {
+				return Decision{}, err
+			}
+			return EditArguments(args), nil
+		}
+	}
+}
+
+// ApprovalRequest is a pending tool call waiting on a decision from a
+// ChannelApprover.
+type ApprovalRequest struct {
+	Call  agent.ToolCall
+	reply chan Decision
+}
+
+// Reply delivers the decision for the request. Only the first reply counts;
+// later ones are ignored.
+func (r *ApprovalRequest) Reply(d Decision) {
+	select {
+	case r.reply <- d:
+	default:
+	}
+}
+
+// ChannelApprover delivers tool calls to a channel so that decisions can be
+// made elsewhere, such as in a UI.
+type ChannelApprover struct {
+	requests chan *ApprovalRequest
+}
+
+func NewChannelApprover() *ChannelApprover {
+	return &ChannelApprover{
+		requests: make(chan *ApprovalRequest),
+	}
+}
+
+// Requests returns the channel of tool calls waiting on approval. Each
+// request must be replied to.
+func (c *ChannelApprover) Requests() <-chan *ApprovalRequest {
+	return c.requests
+}
+
+func (c *ChannelApprover) Approve(ctx context.Context, call agent.ToolCall) (Decision, error) {
+	r := &ApprovalRequest{
+		Call:  call,
+		reply: make(chan Decision, 1),
+	}
+
+	select {
+	case c.requests <- r:
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+
+	select {
+	case d := <-r.reply:
+		return d, nil
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+}
+
+// RuleApprover approves or denies tool calls based on their names.
+//
+// Names are matched with path.Match, so patterns like "read_*" are allowed.
+// Deny rules take precedence. Calls matching no rule are passed to Fallback,
+// or denied if there is none.
+type RuleApprover struct {
+	Allow    []string
+	Deny     []string
+	Fallback Approver
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RuleApprover) Approve(ctx context.Context, call agent.ToolCall) (Decision, error) {
+	switch {
+	case matchAny(r.Deny, call.Name):
+		return Deny("not permitted"), nil
+	case matchAny(r.Allow, call.Name):
+		return Allow(), nil
+	case r.Fallback != nil:
+		return r.Fallback.Approve(ctx, call)
+	default:
+		return Deny("not permitted"), nil
+	}
+}
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func echo(ctx context.Context, args string) (string, error) {
+	return args, nil
+}
+
+func TestApprover(t *testing.T) {
+	ctx := context.Background()
+
+	approver := ApproverFunc(func(ctx context.Context, call agent.ToolCall) (Decision, error) {
+		switch call.ID {
+		case "allow":
+			return Allow(), nil
+		case "edit":
+			return EditArguments(`{"path": "safe.txt"}`), nil
+		default:
+			return Deny("writes are not allowed"), nil
+		}
+	})
+
+	ts := New(WithApprover(approver))
+	ts.Add("echo", "Echo arguments", EmptyParameters, echo)
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "allow", Name: "echo", Arguments: `{}`})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, `{}`, content)
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "edit", Name: "echo", Arguments: `{}`})
+	require.NoError(t, err)
+	content, _ = msg.Content(ctx)
+	assert.Equal(t, `{"path": "safe.txt"}`, content)
+	assert.Equal(t, "edit", msg.ToolCallID)
+	assert.Equal(t, `{"path": "safe.txt"}`, msg.GetAttr(EditedArgumentsAttr))
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "deny", Name: "echo", Arguments: `{}`})
+	require.NoError(t, err)
+	content, _ = msg.Content(ctx)
+	assert.Equal(t, "tool call denied: writes are not allowed", content)
+	assert.Equal(t, "deny", msg.ToolCallID)
+	assert.True(t, msg.HasTag(DeniedTag))
+}
+
+func TestTerminalApprover(t *testing.T) {
+	ctx := context.Background()
+	out := &bytes.Buffer{}
+	in := strings.NewReader("maybe\ny\nn\ntoo risky\ne\n{\"a\": 1}\n")
+
+	a := NewTerminalApprover(in, out)
+	call := agent.ToolCall{ID: "1", Name: "rm", Arguments: `{}`}
+
+	d, err := a.Approve(ctx, call)
+	require.NoError(t, err)
+	assert.Equal(t, Allow(), d)
+	assert.Contains(t, out.String(), "Run tool rm({})? [y]es, [n]o, [e]dit: ")
+
+	d, err = a.Approve(ctx, call)
+	require.NoError(t, err)
+	assert.Equal(t, Deny("too risky"), d)
+
+	d, err = a.Approve(ctx, call)
+	require.NoError(t, err)
+	assert.Equal(t, EditArguments(`{"a": 1}`), d)
+
+	_, err = a.Approve(ctx, call)
+	assert.Error(t, err)
+}
+
+func TestChannelApprover(t *testing.T) {
+	ctx := context.Background()
+	a := NewChannelApprover()
+
+	reqs := make(chan *ApprovalRequest, 1)
+	go func() {
+		r := <-a.Requests()
+		assert.Equal(t, "rm", r.Call.Name)
+		r.Reply(Deny("no"))
+		reqs <- r
+	}()
+
+	d, err := a.Approve(ctx, agent.ToolCall{ID: "1", Name: "rm"})
+	require.NoError(t, err)
+	assert.Equal(t, Deny("no"), d)
+
+	// Replying again after the decision was taken doesn't block.
+	replied := make(chan struct{})
+	go func() {
+		defer close(replied)
+		r := <-reqs
+		r.Reply(Allow())
+		r.Reply(Allow())
+	}()
+
+	select {
+	case <-replied:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply blocked")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = a.Approve(cctx, agent.ToolCall{ID: "2", Name: "rm"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRuleApprover(t *testing.T) {
+	ctx := context.Background()
+
+	a := &RuleApprover{
+		Allow: []string{"read_*", "list_directory"},
+		Deny:  []string{"read_secret"},
+	}
+
+	for name, expected := range map[string]Verdict{
+		"read_file":      VerdictAllow,
+		"list_directory": VerdictAllow,
+		"read_secret":    VerdictDeny,
+		"write_file":     VerdictDeny,
+	} {
+		d, err := a.Approve(ctx, agent.ToolCall{Name: name})
+		require.NoError(t, err)
+		assert.Equal(t, expected, d.Verdict, name)
+	}
+
+	a.Fallback = ApproverFunc(func(ctx context.Context, call agent.ToolCall) (Decision, error) {
+		return Allow(), nil
+	})
+
+	d, err := a.Approve(ctx, agent.ToolCall{Name: "write_file"})
+	require.NoError(t, err)
+	assert.Equal(t, VerdictAllow, d.Verdict)
+}
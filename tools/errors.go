@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/rhettg/agent"
+)
+
+// ErrorTag is set on tool messages that report an error to the model.
+const ErrorTag = "tools:error"
+
+// ErrorAction describes how a failed tool call should be handled.
+type ErrorAction int
+
+const (
+	// ErrorAbort returns the error from the step. This is the default.
+	ErrorAbort ErrorAction = iota
+
+	// ErrorSurface reports the error to the model as the tool result.
+	ErrorSurface
+
+	// ErrorRetry calls the tool again with the same arguments.
+	ErrorRetry
+)
+
+// ErrorHandler decides what to do when a tool returns an error or panics.
+// attempt starts at 1 and increases with each retry.
+type ErrorHandler func(ctx context.Context, call agent.ToolCall, attempt int, err error) ErrorAction
+
+// WithErrorHandler configures how errors from tools are handled.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(f *Tools) {
+		f.errorHandler = h
+	}
+}
+
+// SurfaceErrors is an ErrorHandler that reports every error to the model.
+func SurfaceErrors(ctx context.Context, call agent.ToolCall, attempt int, err error) ErrorAction {
+	return ErrorSurface
+}
+
+// RetryErrors returns an ErrorHandler that retries a failed call up to n
+// times before reporting the error to the model.
+func RetryErrors(n int) ErrorHandler {
+	return func(ctx context.Context, call agent.ToolCall, attempt int, err error) ErrorAction {
+		if attempt <= n {
+			return ErrorRetry
+		}
+		return ErrorSurface
+	}
+}
+
+// PanicError is the error produced when a tool panics.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("tool panicked: %v", e.Value)
+}
+
+// ToolError is the payload of a tool message reporting an error.
+type ToolError struct {
+	Error string `json:"error"`
+	Type  string `json:"type"`
+	Tool  string `json:"tool"`
+}
+
+func invoke(ctx context.Context, fn ResultTool, arguments string) (res *Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn(ctx, arguments)
+}
+
+func newErrorMessage(toolCall *agent.ToolCall, err error) *agent.Message {
+	te := ToolError{
+		Error: err.Error(),
+		Type:  "error",
+		Tool:  toolCall.Name,
+	}
+
+	var panicErr *PanicError
+	var timeoutErr *TimeoutError
+	switch {
+	case errors.As(err, &panicErr):
+		te.Type = "panic"
+	case errors.As(err, &timeoutErr):
+		te.Type = "timeout"
+	}
+
+	// Marshaling a struct of strings cannot fail.
+	data, _ := json.Marshal(te)
+
+	m := agent.NewContentMessage(agent.RoleTool, string(data))
+	m.ToolCallID = toolCall.ID
+	m.Tag(ErrorTag)
+
+	return m
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolErrorAbort(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	ts.Add("fail", "Always fails", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		return "", errors.New("bad path")
+	})
+
+	_, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "fail"})
+	assert.EqualError(t, err, "bad path")
+}
+
+func TestToolErrorSurface(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithErrorHandler(SurfaceErrors))
+
+	ts.Add("fail", "Always fails", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		return "", errors.New("bad path")
+	})
+	ts.Add("panic", "Always panics", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		panic("oops")
+	})
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "fail"})
+	require.NoError(t, err)
+	assert.Equal(t, agent.RoleTool, msg.Role)
+	assert.Equal(t, "1", msg.ToolCallID)
+	assert.True(t, msg.HasTag(ErrorTag))
+
+	var te ToolError
+	content, _ := msg.Content(ctx)
+	require.NoError(t, json.Unmarshal([]byte(content), &te))
+	assert.Equal(t, ToolError{Error: "bad path", Type: "error", Tool: "fail"}, te)
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: "panic"})
+	require.NoError(t, err)
+	content, _ = msg.Content(ctx)
+	require.NoError(t, json.Unmarshal([]byte(content), &te))
+	assert.Equal(t, ToolError{Error: "tool panicked: oops", Type: "panic", Tool: "panic"}, te)
+}
+
+func TestToolErrorRetry(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithErrorHandler(RetryErrors(2)))
+
+	attempts := 0
+	ts.Add("flaky", "Fails twice", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", errors.New("try again")
+		}
+		return "ok", nil
+	})
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "flaky"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, "ok", content)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestToolErrorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := New(WithErrorHandler(SurfaceErrors))
+
+	ts.Add("cancel", "Cancels the step", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		cancel()
+		return "", ctx.Err()
+	})
+
+	_, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "cancel"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
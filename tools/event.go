@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/rhettg/agent"
+)
+
+// WithObserver calls o when each tool call starts and finishes. Parallel
+// calls report their events concurrently.
+//
+// Tool events are also sent to the observers of the agent running the step,
+// so this is only needed to watch the tools on their own, such as when using
+// Call directly.
+func WithObserver(o agent.Observer) Option {
+	return func(f *Tools) {
+		f.observers = append(f.observers, o)
+	}
+}
+
+func (f *Tools) emit(ctx context.Context, e agent.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for _, o := range f.observers {
+		o(ctx, e)
+	}
+	agent.Emit(ctx, e)
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserver(t *testing.T) {
+	var mu sync.Mutex
+	var events []agent.Event
+	observe := func(ctx context.Context, e agent.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	ts := New(WithObserver(observe))
+	require.NoError(t, ts.Add("echo", "", nil, func(ctx context.Context, args string) (string, error) {
+		return args, nil
+	}))
+	require.NoError(t, ts.Add("fail", "", nil, func(ctx context.Context, args string) (string, error) {
+		return "", errors.New("broken")
+	}))
+
+	m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "echo", Arguments: "{}"})
+	require.NoError(t, err)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, agent.EventToolCallStarted, events[0].Type)
+	assert.Equal(t, "echo", events[0].ToolCall.Name)
+	assert.Equal(t, agent.EventToolCallFinished, events[1].Type)
+	assert.Equal(t, "call_1", events[1].ToolCall.ID)
+	assert.Same(t, m, events[1].Message)
+	assert.False(t, events[1].Time.Before(events[0].Time))
+
+	_, err = ts.Call(context.Background(), agent.ToolCall{ID: "call_2", Name: "fail", Arguments: "{}"})
+	require.Error(t, err)
+	require.Len(t, events, 4)
+	assert.ErrorIs(t, events[3].Err, err)
+}
+
+func TestObserverAgent(t *testing.T) {
+	ts := New()
+	require.NoError(t, ts.Add("echo", "", nil, func(ctx context.Context, args string) (string, error) {
+		return args, nil
+	}))
+
+	var types []agent.EventType
+	observe := func(ctx context.Context, e agent.Event) {
+		types = append(types, e.Type)
+	}
+
+	a := agent.New(nil, WithTools(ts), agent.WithObserver(observe))
+	m := agent.NewContentMessage(agent.RoleAssistant, "")
+	m.ToolCalls = []agent.ToolCall{{ID: "call_1", Name: "echo", Arguments: "{}"}}
+	a.AddMessage(m)
+
+	_, err := a.Step(context.Background())
+	require.NoError(t, err)
+
+	// Tool events reach the agent's observers without tools.WithObserver.
+	assert.Equal(t, []agent.EventType{
+		agent.EventMessageAdded,
+		agent.EventStepStarted,
+		agent.EventToolCallStarted,
+		agent.EventToolCallFinished,
+		agent.EventMessageAdded,
+		agent.EventStepFinished,
+	}, types)
+}
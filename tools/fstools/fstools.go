@@ -0,0 +1,181 @@
+// Package fstools provides tools for working with files in a directory.
+package fstools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rhettg/agent/tools"
+)
+
+var (
+	// ErrEscape is returned for paths outside the root.
+	ErrEscape = errors.New("path escapes root")
+
+	// ErrSymlink is returned for paths that pass through a symbolic link,
+	// which could point anywhere.
+	ErrSymlink = errors.New("path contains a symbolic link")
+)
+
+const (
+	defaultReadLines  = 500
+	defaultMaxResults = 500
+)
+
+// FS is a set of filesystem tools confined to a root directory.
+type FS struct {
+	root       string
+	readOnly   bool
+	readLines  int
+	maxResults int
+}
+
+type Option func(f *FS)
+
+// WithReadOnly omits the tools that modify files.
+func WithReadOnly() Option {
+	return func(f *FS) {
+		f.readOnly = true
+	}
+}
+
+// WithReadLines sets how many lines read_file returns when no limit is given.
+func WithReadLines(n int) Option {
+	return func(f *FS) {
+		f.readLines = n
+	}
+}
+
+// WithMaxResults limits the number of entries returned by glob and grep.
+func WithMaxResults(n int) Option {
+	return func(f *FS) {
+		f.maxResults = n
+	}
+}
+
+// New returns tools rooted at the directory root.
+func New(root string, opts ...Option) (*FS, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+
+	// The root itself may be a symlink; only links beneath it are refused.
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid root: %s is not a directory", root)
+	}
+
+	f := &FS{
+		root:       abs,
+		readLines:  defaultReadLines,
+		maxResults: defaultMaxResults,
+	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	return f, nil
+}
+
+// Root returns the absolute path of the root directory.
+func (f *FS) Root() string {
+	return f.root
+}
+
+// Tools returns the filesystem tools. Errors such as a missing file are
+// returned from the tools, so use tools.SurfaceErrors to show them to the
+// model.
+func (f *FS) Tools() (*tools.Tools, error) {
+	ts := tools.New()
+
+	err := errors.Join(
+		tools.AddFunc(ts, "list_directory", "List the files and directories in a directory.", f.listDirectory),
+		tools.AddFunc(ts, "read_file", "Read a text file. Lines are numbered and long files are returned in ranges.", f.readFile),
+		tools.AddFunc(ts, "glob", "Find files whose paths match a pattern. Use ** to match any number of directories, e.g. **/*.go.", f.glob),
+		tools.AddFunc(ts, "grep", "Search file contents for a regular expression.", f.grep),
+		tools.AddFunc(ts, "stat", "Describe a file or directory.", f.stat),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.readOnly {
+		return ts, nil
+	}
+
+	err = errors.Join(
+		tools.AddFunc(ts, "write_file", "Create or overwrite a file, creating parent directories as needed.", f.writeFile),
+		tools.AddFunc(ts, "edit_file", "Replace text in a file. old_string must match exactly once unless replace_all is set.", f.editFile),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// resolve maps a path from the model to a path on disk. Relative paths are
+// relative to the root and absolute paths must be inside it. No component
+// below the root may be a symbolic link. Components that don't exist yet are
+// allowed so files can be created.
+func (f *FS) resolve(name string) (string, error) {
+	if name == "" {
+		name = "."
+	}
+
+	p := filepath.FromSlash(name)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(f.root, p)
+	}
+	p = filepath.Clean(p)
+
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrEscape, name)
+	}
+
+	if rel == "." {
+		return p, nil
+	}
+
+	cur := f.root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+
+		info, err := os.Lstat(cur)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s", ErrSymlink, name)
+		}
+	}
+
+	return p, nil
+}
+
+// display returns the path shown to the model, relative to the root.
+func (f *FS) display(p string) string {
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
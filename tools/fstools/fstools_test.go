@@ -0,0 +1,276 @@
+package fstools
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T, opts ...Option) (*FS, string) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"README.md":            "# Project\n",
+		"main.go":              "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"pkg/util.go":          "package pkg\n\n// Hello says hello.\nfunc Hello() {}\n",
+		"pkg/sub/deep.go":      "package sub\n",
+		"pkg/sub/deep_test.go": "package sub\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+	f, err := New(dir, opts...)
+	require.NoError(t, err)
+
+	return f, dir
+}
+
+func TestResolve(t *testing.T) {
+	f, _ := setup(t)
+
+	for _, name := range []string{"", ".", "main.go", "pkg/../main.go", "new/file.txt", filepath.Join(f.Root(), "pkg")} {
+		_, err := f.resolve(name)
+		assert.NoError(t, err, name)
+	}
+
+	for _, name := range []string{"..", "../x", "pkg/../../x", "/etc/passwd"} {
+		_, err := f.resolve(name)
+		assert.ErrorIs(t, err, ErrEscape, name)
+	}
+
+	for _, name := range []string{"link", "link/secret", "link/new"} {
+		_, err := f.resolve(name)
+		assert.ErrorIs(t, err, ErrSymlink, name)
+	}
+}
+
+func TestNewSymlinkRoot(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "real"), 0o755))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "real"), filepath.Join(dir, "root")))
+
+	f, err := New(filepath.Join(dir, "root"))
+	require.NoError(t, err)
+
+	_, err = f.resolve("file")
+	assert.NoError(t, err)
+
+	_, err = New(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestListDirectory(t *testing.T) {
+	f, _ := setup(t)
+
+	out, err := f.listDirectory(context.Background(), listDirectoryArgs{})
+	require.NoError(t, err)
+	assert.Equal(t, "Contents of .:\n  README.md (10 bytes)\n  link (symlink)\n  main.go (48 bytes)\n  pkg/\n", out)
+
+	_, err = f.listDirectory(context.Background(), listDirectoryArgs{Path: "link"})
+	assert.ErrorIs(t, err, ErrSymlink)
+}
+
+func TestReadFile(t *testing.T) {
+	f, _ := setup(t, WithReadLines(2))
+	ctx := context.Background()
+
+	out, err := f.readFile(ctx, readFileArgs{Path: "main.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "     1\tpackage main\n     2\t\n[showing lines 1-2 of 5; use start_line 3 to read more]\n", out)
+
+	out, err = f.readFile(ctx, readFileArgs{Path: "main.go", StartLine: 4, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "     4\t\tprintln(\"hello\")\n     5\t}\n[showing lines 4-5 of 5]\n", out)
+
+	out, err = f.readFile(ctx, readFileArgs{Path: "README.md"})
+	require.NoError(t, err)
+	assert.Equal(t, "     1\t# Project\n", out)
+
+	_, err = f.readFile(ctx, readFileArgs{Path: "main.go", StartLine: 6})
+	assert.Error(t, err)
+
+	_, err = f.readFile(ctx, readFileArgs{Path: "link/secret"})
+	assert.ErrorIs(t, err, ErrSymlink)
+
+	require.NoError(t, os.WriteFile(filepath.Join(f.Root(), "bin"), []byte{0x7f, 'E', 'L', 'F', 0}, 0o644))
+	_, err = f.readFile(ctx, readFileArgs{Path: "bin"})
+	assert.EqualError(t, err, "bin is a binary file")
+
+	_, err = f.readFile(ctx, readFileArgs{Path: "pkg"})
+	assert.EqualError(t, err, "pkg is not a regular file")
+
+	big := bytes.Repeat([]byte("x"), maxReadFileSize+1)
+	require.NoError(t, os.WriteFile(filepath.Join(f.Root(), "big"), big, 0o644))
+	_, err = f.readFile(ctx, readFileArgs{Path: "big"})
+	assert.ErrorContains(t, err, "big is too large to read")
+}
+
+func TestClip(t *testing.T) {
+	line := strings.Repeat("a", maxLineLength-1) + "é"
+	clipped := clip(line)
+	assert.True(t, utf8.ValidString(clipped))
+	assert.Equal(t, strings.Repeat("a", maxLineLength-1)+"...", clipped)
+
+	assert.Equal(t, "short", clip("short"))
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/util.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/sub/deep.go", true},
+		{"pkg/**", "pkg/sub/deep.go", true},
+		{"pkg/**/deep.go", "pkg/deep.go", true},
+		{"pkg/*/deep.go", "pkg/deep.go", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name), "%s %s", tt.pattern, tt.name)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	f, _ := setup(t)
+	ctx := context.Background()
+
+	out, err := f.glob(ctx, globArgs{Pattern: "**/*_test.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/sub/deep_test.go\n", out)
+
+	out, err = f.glob(ctx, globArgs{Pattern: "*.go", Path: "pkg"})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/util.go\n", out)
+
+	// Files behind the symlink are not found.
+	out, err = f.glob(ctx, globArgs{Pattern: "**/secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "No files found.", out)
+
+	f.maxResults = 2
+	out, err = f.glob(ctx, globArgs{Pattern: "**/*.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "main.go\npkg/sub/deep.go\n[results truncated at 2 files]\n", out)
+
+	_, err = f.glob(ctx, globArgs{Pattern: "["})
+	assert.Error(t, err)
+}
+
+func TestGrep(t *testing.T) {
+	f, _ := setup(t)
+	ctx := context.Background()
+
+	out, err := f.grep(ctx, grepArgs{Pattern: `^package (main|pkg)$`})
+	require.NoError(t, err)
+	assert.Equal(t, "main.go:1:package main\npkg/util.go:1:package pkg\n", out)
+
+	out, err = f.grep(ctx, grepArgs{Pattern: "Hello", Path: "pkg/util.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/util.go:3:// Hello says hello.\npkg/util.go:4:func Hello() {}\n", out)
+
+	out, err = f.grep(ctx, grepArgs{Pattern: "package", Include: "*_test.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/sub/deep_test.go:1:package sub\n", out)
+
+	out, err = f.grep(ctx, grepArgs{Pattern: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "No matches found.", out)
+
+	_, err = f.grep(ctx, grepArgs{Pattern: "("})
+	assert.Error(t, err)
+}
+
+func TestStat(t *testing.T) {
+	f, _ := setup(t)
+
+	s, err := f.stat(context.Background(), statArgs{Path: "pkg/util.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/util.go", s.Path)
+	assert.Equal(t, "file", s.Type)
+	assert.Equal(t, int64(50), s.Size)
+	assert.Equal(t, "-rw-r--r--", s.Mode)
+
+	s, err = f.stat(context.Background(), statArgs{Path: "pkg"})
+	require.NoError(t, err)
+	assert.Equal(t, "directory", s.Type)
+}
+
+func TestWriteFile(t *testing.T) {
+	f, dir := setup(t)
+	ctx := context.Background()
+
+	out, err := f.writeFile(ctx, writeFileArgs{Path: "new/dir/file.txt", Content: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "Wrote 2 bytes to new/dir/file.txt", out)
+
+	data, err := os.ReadFile(filepath.Join(dir, "new", "dir", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(data))
+
+	_, err = f.writeFile(ctx, writeFileArgs{Path: "link/secret", Content: "pwned"})
+	assert.ErrorIs(t, err, ErrSymlink)
+
+	_, err = f.writeFile(ctx, writeFileArgs{Path: "../escape", Content: "pwned"})
+	assert.ErrorIs(t, err, ErrEscape)
+
+	_, err = f.writeFile(ctx, writeFileArgs{Path: "pkg", Content: "x"})
+	assert.Error(t, err)
+}
+
+func TestEditFile(t *testing.T) {
+	f, dir := setup(t)
+	ctx := context.Background()
+
+	out, err := f.editFile(ctx, editFileArgs{Path: "main.go", OldString: `"hello"`, NewString: `"goodbye"`})
+	require.NoError(t, err)
+	assert.Equal(t, "Replaced 1 occurrence(s) in main.go", out)
+
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `println("goodbye")`)
+
+	_, err = f.editFile(ctx, editFileArgs{Path: "main.go", OldString: "missing", NewString: "x"})
+	assert.EqualError(t, err, "old_string not found in main.go")
+
+	_, err = f.editFile(ctx, editFileArgs{Path: "pkg/util.go", OldString: "Hello", NewString: "Hi"})
+	assert.EqualError(t, err, "old_string matches 2 times in pkg/util.go; include more context or set replace_all")
+
+	_, err = f.editFile(ctx, editFileArgs{Path: "pkg/util.go", OldString: "Hello", NewString: "Hi", ReplaceAll: true})
+	require.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(dir, "pkg", "util.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package pkg\n\n// Hi says hello.\nfunc Hi() {}\n", string(data))
+}
+
+func TestTools(t *testing.T) {
+	f, _ := setup(t)
+
+	ts, err := f.Tools()
+	require.NoError(t, err)
+	assert.Len(t, ts.Defs(), 7)
+	assert.True(t, ts.Has("edit_file"))
+
+	ro, _ := setup(t, WithReadOnly())
+	ts, err = ro.Tools()
+	require.NoError(t, err)
+	assert.Len(t, ts.Defs(), 5)
+	assert.False(t, ts.Has("write_file"))
+	assert.False(t, ts.Has("edit_file"))
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package fstools
+
+import (
+	"context"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFileFIFO(t *testing.T) {
+	f, _ := setup(t)
+	ctx := context.Background()
+
+	// Opening a FIFO with no writer would block forever.
+	require.NoError(t, syscall.Mkfifo(filepath.Join(f.Root(), "fifo"), 0o644))
+
+	_, err := f.readFile(ctx, readFileArgs{Path: "fifo"})
+	assert.EqualError(t, err, "fifo is not a regular file")
+
+	_, err = f.editFile(ctx, editFileArgs{Path: "fifo", OldString: "a", NewString: "b"})
+	assert.EqualError(t, err, "fifo is not a regular file")
+}
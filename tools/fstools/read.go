@@ -0,0 +1,390 @@
+package fstools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxGrepFileSize skips files too large to be worth searching.
+	maxGrepFileSize = 10 << 20
+
+	// maxReadFileSize refuses files too large to read or edit whole.
+	maxReadFileSize = 10 << 20
+
+	maxLineLength = 500
+)
+
+// isBinary guesses whether data is binary by looking for a NUL byte near the
+// start, as git does.
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// clip shortens long lines, cutting on a character boundary.
+func clip(line string) string {
+	if len(line) <= maxLineLength {
+		return line
+	}
+
+	n := maxLineLength
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	return line[:n] + "..."
+}
+
+// readRegularFile reads a whole file, refusing anything else, such as a
+// FIFO that would block forever, and files too large to return.
+func (f *FS) readRegularFile(p string) ([]byte, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", f.display(p), err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", f.display(p))
+	}
+	if info.Size() > maxReadFileSize {
+		return nil, fmt.Errorf("%s is too large to read (%d bytes)", f.display(p), info.Size())
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", f.display(p), err)
+	}
+	return data, nil
+}
+
+type listDirectoryArgs struct {
+	Path string `json:"path,omitempty" description:"Directory to list, relative to the root. Defaults to the root."`
+}
+
+func (f *FS) listDirectory(ctx context.Context, args listDirectoryArgs) (string, error) {
+	p, err := f.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return "", fmt.Errorf("error reading directory %s: %w", f.display(p), err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Contents of %s:\n", f.display(p))
+	if len(entries) == 0 {
+		b.WriteString("  (empty)\n")
+	}
+
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir():
+			fmt.Fprintf(&b, "  %s/\n", entry.Name())
+		case entry.Type()&fs.ModeSymlink != 0:
+			fmt.Fprintf(&b, "  %s (symlink)\n", entry.Name())
+		default:
+			if info, err := entry.Info(); err == nil {
+				fmt.Fprintf(&b, "  %s (%d bytes)\n", entry.Name(), info.Size())
+			} else {
+				fmt.Fprintf(&b, "  %s\n", entry.Name())
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+type readFileArgs struct {
+	Path      string `json:"path" description:"File to read, relative to the root."`
+	StartLine int    `json:"start_line,omitempty" description:"First line to read, starting at 1."`
+	Limit     int    `json:"limit,omitempty" description:"Maximum number of lines to read."`
+}
+
+func (f *FS) readFile(ctx context.Context, args readFileArgs) (string, error) {
+	p, err := f.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := f.readRegularFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is a binary file", f.display(p))
+	}
+
+	if len(data) == 0 {
+		return "(empty file)", nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	start := max(args.StartLine, 1)
+	if start > len(lines) {
+		return "", fmt.Errorf("start_line %d is past the end of %s, which has %d lines", start, f.display(p), len(lines))
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = f.readLines
+	}
+	end := min(start-1+limit, len(lines))
+
+	var b strings.Builder
+	for i := start - 1; i < end; i++ {
+		fmt.Fprintf(&b, "%6d\t%s\n", i+1, clip(lines[i]))
+	}
+
+	if start > 1 || end < len(lines) {
+		fmt.Fprintf(&b, "[showing lines %d-%d of %d", start, end, len(lines))
+		if end < len(lines) {
+			fmt.Fprintf(&b, "; use start_line %d to read more", end+1)
+		}
+		b.WriteString("]\n")
+	}
+
+	return b.String(), nil
+}
+
+// validGlob reports whether each segment of the pattern is well formed.
+func validGlob(pattern string) bool {
+	for _, seg := range strings.Split(pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchGlob matches a slash separated path against a pattern in which **
+// matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// walkFiles calls fn for each regular file beneath dir. Symbolic links are
+// not followed.
+func walkFiles(ctx context.Context, dir string, fn func(p string, d fs.DirEntry) (bool, error)) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories rather than failing the search.
+			if d != nil && d.IsDir() && p != dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		more, err := fn(p, d)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
+
+type globArgs struct {
+	Pattern string `json:"pattern" description:"Pattern to match, relative to path, e.g. **/*_test.go."`
+	Path    string `json:"path,omitempty" description:"Directory to search in. Defaults to the root."`
+}
+
+func (f *FS) glob(ctx context.Context, args globArgs) (string, error) {
+	if !validGlob(args.Pattern) {
+		return "", fmt.Errorf("invalid pattern: %s", args.Pattern)
+	}
+
+	dir, err := f.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	truncated := false
+	err = walkFiles(ctx, dir, func(p string, d fs.DirEntry) (bool, error) {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return false, err
+		}
+
+		if matchGlob(args.Pattern, filepath.ToSlash(rel)) {
+			if len(matches) == f.maxResults {
+				truncated = true
+				return false, nil
+			}
+			matches = append(matches, f.display(p))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error searching %s: %w", f.display(dir), err)
+	}
+
+	if len(matches) == 0 {
+		return "No files found.", nil
+	}
+
+	out := strings.Join(matches, "\n") + "\n"
+	if truncated {
+		out += fmt.Sprintf("[results truncated at %d files]\n", f.maxResults)
+	}
+	return out, nil
+}
+
+type grepArgs struct {
+	Pattern string `json:"pattern" description:"Regular expression in Go syntax."`
+	Path    string `json:"path,omitempty" description:"File or directory to search. Defaults to the root."`
+	Include string `json:"include,omitempty" description:"Only search files whose names match this pattern, e.g. *.go."`
+}
+
+func (f *FS) grep(ctx context.Context, args grepArgs) (string, error) {
+	re, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	if args.Include != "" {
+		if _, err := path.Match(args.Include, ""); err != nil {
+			return "", fmt.Errorf("invalid include pattern: %s", args.Include)
+		}
+	}
+
+	dir, err := f.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	truncated := false
+	err = walkFiles(ctx, dir, func(p string, d fs.DirEntry) (bool, error) {
+		if args.Include != "" {
+			if ok, _ := path.Match(args.Include, d.Name()); !ok {
+				return true, nil
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxGrepFileSize {
+			return true, nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil || isBinary(data) {
+			return true, nil
+		}
+
+		s := bufio.NewScanner(bytes.NewReader(data))
+		s.Buffer(nil, len(data)+1)
+		for n := 1; s.Scan(); n++ {
+			if !re.MatchString(s.Text()) {
+				continue
+			}
+			if len(matches) == f.maxResults {
+				truncated = true
+				return false, nil
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d:%s", f.display(p), n, clip(s.Text())))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error searching %s: %w", f.display(dir), err)
+	}
+
+	if len(matches) == 0 {
+		return "No matches found.", nil
+	}
+
+	out := strings.Join(matches, "\n") + "\n"
+	if truncated {
+		out += fmt.Sprintf("[results truncated at %d matches]\n", f.maxResults)
+	}
+	return out, nil
+}
+
+type statArgs struct {
+	Path string `json:"path" description:"File or directory, relative to the root."`
+}
+
+type statResult struct {
+	Path     string    `json:"path"`
+	Type     string    `json:"type"`
+	Size     int64     `json:"size"`
+	Mode     string    `json:"mode"`
+	Modified time.Time `json:"modified"`
+}
+
+func (f *FS) stat(ctx context.Context, args statArgs) (statResult, error) {
+	p, err := f.resolve(args.Path)
+	if err != nil {
+		return statResult{}, err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return statResult{}, fmt.Errorf("error reading %s: %w", f.display(p), err)
+	}
+
+	typ := "file"
+	if info.IsDir() {
+		typ = "directory"
+	} else if !info.Mode().IsRegular() {
+		typ = "other"
+	}
+
+	return statResult{
+		Path:     f.display(p),
+		Type:     typ,
+		Size:     info.Size(),
+		Mode:     info.Mode().Perm().String(),
+		Modified: info.ModTime(),
+	}, nil
+}
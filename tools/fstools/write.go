@@ -0,0 +1,79 @@
+package fstools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type writeFileArgs struct {
+	Path    string `json:"path" description:"File to write, relative to the root."`
+	Content string `json:"content" description:"The complete new contents of the file."`
+}
+
+func (f *FS) writeFile(ctx context.Context, args writeFileArgs) (string, error) {
+	p, err := f.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", f.display(p))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", fmt.Errorf("error creating directory for %s: %w", f.display(p), err)
+	}
+
+	if err := os.WriteFile(p, []byte(args.Content), 0o644); err != nil {
+		return "", fmt.Errorf("error writing file %s: %w", f.display(p), err)
+	}
+
+	return fmt.Sprintf("Wrote %d bytes to %s", len(args.Content), f.display(p)), nil
+}
+
+type editFileArgs struct {
+	Path       string `json:"path" description:"File to edit, relative to the root."`
+	OldString  string `json:"old_string" description:"Exact text to replace, including enough surrounding context to be unique."`
+	NewString  string `json:"new_string" description:"Text to replace it with."`
+	ReplaceAll bool   `json:"replace_all,omitempty" description:"Replace every occurrence instead of requiring a unique match."`
+}
+
+func (f *FS) editFile(ctx context.Context, args editFileArgs) (string, error) {
+	if args.OldString == "" {
+		return "", fmt.Errorf("old_string must not be empty")
+	}
+
+	p, err := f.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := f.readRegularFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", fmt.Errorf("error reading file %s: %w", f.display(p), err)
+	}
+	content := string(data)
+
+	n := strings.Count(content, args.OldString)
+	switch {
+	case n == 0:
+		return "", fmt.Errorf("old_string not found in %s", f.display(p))
+	case n > 1 && !args.ReplaceAll:
+		return "", fmt.Errorf("old_string matches %d times in %s; include more context or set replace_all", n, f.display(p))
+	}
+
+	content = strings.ReplaceAll(content, args.OldString, args.NewString)
+	if err := os.WriteFile(p, []byte(content), info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("error writing file %s: %w", f.display(p), err)
+	}
+
+	return fmt.Sprintf("Replaced %d occurrence(s) in %s", n, f.display(p)), nil
+}
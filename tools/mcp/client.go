@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rhettg/agent/tools"
+)
+
+var clientInfo = Implementation{Name: "github.com/rhettg/agent", Version: "0.1.0"}
+
+// Client is a connection to an MCP server.
+type Client struct {
+	t      Transport
+	nextID atomic.Int64
+
+	server InitializeResult
+}
+
+// Connect performs the MCP handshake over the transport.
+func Connect(ctx context.Context, t Transport) (*Client, error) {
+	c := &Client{t: t}
+
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      clientInfo,
+	}
+
+	if err := c.call(ctx, "initialize", params, &c.server); err != nil {
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	return c, nil
+}
+
+// Server returns the details the server provided during initialization.
+func (c *Client) Server() InitializeResult {
+	return c.server
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	req, err := newRequest(c.nextID.Add(1), method, params)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.t.RoundTrip(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	req, err := newRequest(0, method, params)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.t.RoundTrip(ctx, req)
+	return err
+}
+
+// ListTools returns all tools offered by the server.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+
+	params := ListToolsParams{}
+	for {
+		var result ListToolsResult
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+
+		all = append(all, result.Tools...)
+
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		params.Cursor = result.NextCursor
+	}
+}
+
+// CallTool invokes a tool on the server. A result with IsError set means the
+// tool itself failed, which is not reported as an error.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	params := CallToolParams{
+		Name:      name,
+		Arguments: arguments,
+	}
+
+	result := &CallToolResult{}
+	if err := c.call(ctx, "tools/call", params, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Tools lists the server's tools and returns them as a tool set whose
+// functions call the server.
+func (c *Client) Tools(ctx context.Context) (*tools.Tools, error) {
+	mts, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+
+	ts := tools.New()
+	for _, mt := range mts {
+		var params map[string]any
+		if len(mt.InputSchema) > 0 {
+			if err := json.Unmarshal(mt.InputSchema, &params); err != nil {
+				return nil, fmt.Errorf("invalid schema for tool %s: %w", mt.Name, err)
+			}
+		}
+
+		if params == nil {
+			params = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+
+		if err := ts.AddResultTool(mt.Name, mt.Description, params, c.tool(mt.Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return ts, nil
+}
+
+func (c *Client) tool(name string) tools.ResultTool {
+	return func(ctx context.Context, arguments string) (*tools.Result, error) {
+		var args json.RawMessage
+		if strings.TrimSpace(arguments) != "" {
+			args = json.RawMessage(arguments)
+		}
+
+		result, err := c.CallTool(ctx, name, args)
+		if err != nil {
+			return nil, err
+		}
+
+		content := resultText(result)
+		if result.IsError {
+			content = "error: " + content
+		}
+
+		res := tools.NewResult(tools.Text(content))
+		for _, rc := range result.Content {
+			if rc.Type != "image" {
+				continue
+			}
+
+			img, err := decodeImage(rc)
+			if err != nil {
+				return nil, err
+			}
+			res.Parts = append(res.Parts, tools.Part{Image: &img})
+		}
+
+		return res, nil
+	}
+}
+
+// resultText renders the content of a tool result as text. Images are
+// returned separately, so they are only mentioned.
+func resultText(r *CallToolResult) string {
+	parts := make([]string, 0, len(r.Content))
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "resource":
+			if c.Resource != nil && c.Resource.Text != "" {
+				parts = append(parts, c.Resource.Text)
+			} else if c.Resource != nil {
+				parts = append(parts, fmt.Sprintf("[resource: %s]", c.Resource.URI))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s: %s]", c.Type, c.MimeType))
+		}
+	}
+
+	if len(parts) == 0 && len(r.StructuredContent) > 0 {
+		return string(r.StructuredContent)
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// Close closes the underlying transport.
+func (c *Client) Close() error {
+	return c.t.Close()
+}
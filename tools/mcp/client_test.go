@@ -0,0 +1,221 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/rhettg/agent/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The test binary doubles as a stub MCP server when this variable is set.
+const stubServerEnv = "MCP_STUB_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubServerEnv) == "1" {
+		runStubServer(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+var stubTools = []Tool{
+	{
+		Name:        "echo",
+		Description: "Echo the message",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"message":{"type":"string"}},"required":["message"]}`),
+	},
+	{
+		Name:        "fail",
+		Description: "Always fails",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{}}`),
+	},
+}
+
+// stubHandle implements just enough of an MCP server for the tests.
+func stubHandle(req *Request) *Response {
+	resp := &Response{JSONRPC: jsonrpcVersion, ID: req.ID}
+
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      Implementation{Name: "stub", Version: "1.0"},
+		}
+	case "tools/list":
+		// Page through the tools one at a time.
+		var params ListToolsParams
+		_ = json.Unmarshal(req.Params, &params)
+		if params.Cursor == "" {
+			result = ListToolsResult{Tools: stubTools[:1], NextCursor: "page2"}
+		} else {
+			result = ListToolsResult{Tools: stubTools[1:]}
+		}
+	case "tools/call":
+		var params CallToolParams
+		_ = json.Unmarshal(req.Params, &params)
+
+		switch params.Name {
+		case "echo":
+			var args struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(params.Arguments, &args)
+			result = CallToolResult{Content: []Content{{Type: "text", Text: args.Message}}}
+		case "fail":
+			result = CallToolResult{Content: []Content{{Type: "text", Text: "it broke"}}, IsError: true}
+		default:
+			resp.Error = &Error{Code: CodeInvalidParams, Message: "unknown tool"}
+			return resp
+		}
+	default:
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: "method not found"}
+		return resp
+	}
+
+	resp.Result, _ = json.Marshal(result)
+	return resp
+}
+
+func runStubServer(r io.Reader, w io.Writer) {
+	enc := json.NewEncoder(w)
+
+	// Exercise the client's handling of server requests and notifications.
+	_ = enc.Encode(Request{JSONRPC: jsonrpcVersion, Method: "notifications/message", Params: json.RawMessage(`{}`)})
+	_ = enc.Encode(Request{JSONRPC: jsonrpcVersion, ID: json.RawMessage(`"srv-1"`), Method: "ping"})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil || !m.isRequest() || len(m.ID) == 0 {
+			continue
+		}
+		_ = enc.Encode(stubHandle(m.request()))
+	}
+}
+
+func testClientTools(t *testing.T, c *Client) {
+	ctx := context.Background()
+
+	assert.Equal(t, "stub", c.Server().ServerInfo.Name)
+
+	ts, err := c.Tools(ctx)
+	require.NoError(t, err)
+
+	defs := ts.Defs()
+	require.Len(t, defs, 2)
+	assert.Equal(t, "echo", defs[0].Name)
+	assert.Equal(t, "Echo the message", defs[0].Description)
+	assert.Equal(t, []any{"message"}, defs[0].Parameters.(map[string]any)["required"])
+
+	a := agent.New(func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		t.Fatal("tool calls should be handled by the tools")
+		return nil, nil
+	}, tools.WithTools(ts))
+
+	m := agent.NewContentMessage(agent.RoleAssistant, "")
+	m.ToolCalls = []agent.ToolCall{
+		{ID: "1", Name: "echo", Arguments: `{"message": "hello"}`},
+		{ID: "2", Name: "fail", Arguments: `{}`},
+	}
+	a.AddMessage(m)
+
+	r, err := a.Step(ctx)
+	require.NoError(t, err)
+	content, _ := r.Content(ctx)
+	assert.Equal(t, "hello", content)
+
+	r, err = a.Step(ctx)
+	require.NoError(t, err)
+	content, _ = r.Content(ctx)
+	assert.Equal(t, "error: it broke", content)
+
+	_, err = c.CallTool(ctx, "missing", nil)
+	var rpcErr *Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeInvalidParams, rpcErr.Code)
+}
+
+func TestStdioClient(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), stubServerEnv+"=1")
+
+	tr, err := NewCommandTransport(cmd)
+	require.NoError(t, err)
+
+	c, err := Connect(context.Background(), tr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	testClientTools(t, c)
+}
+
+func TestHTTPClient(t *testing.T) {
+	var sessions []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		if r.Method == http.MethodDelete {
+			sessions = append(sessions, "closed:"+r.Header.Get(sessionHeader))
+			return
+		}
+
+		var req Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		if req.Method == "initialize" {
+			w.Header().Set(sessionHeader, "session-1")
+		} else {
+			sessions = append(sessions, r.Header.Get(sessionHeader))
+			assert.Equal(t, ProtocolVersion, r.Header.Get(protocolHeader))
+		}
+
+		if req.IsNotification() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		resp := stubHandle(&req)
+		data, _ := json.Marshal(resp)
+
+		// Answer tool calls with an event stream and everything else with
+		// plain JSON.
+		if req.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport(srv.URL, WithHeader("Authorization", "Bearer secret"))
+
+	c, err := Connect(context.Background(), tr)
+	require.NoError(t, err)
+
+	testClientTools(t, c)
+
+	require.NoError(t, c.Close())
+	for _, s := range sessions[:len(sessions)-1] {
+		assert.Equal(t, "session-1", s)
+	}
+	assert.Equal(t, "closed:session-1", sessions[len(sessions)-1])
+}
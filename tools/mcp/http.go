@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	sessionHeader  = "Mcp-Session-Id"
+	protocolHeader = "MCP-Protocol-Version"
+)
+
+// HTTPTransport speaks the MCP streamable HTTP transport. Each request is
+// POSTed to the endpoint and the response is read from either a JSON body or
+// a server-sent event stream.
+type HTTPTransport struct {
+	url    string
+	client *http.Client
+	header http.Header
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+type HTTPOption func(t *HTTPTransport)
+
+// WithHTTPClient sets the client used for requests.
+func WithHTTPClient(c *http.Client) HTTPOption {
+	return func(t *HTTPTransport) {
+		t.client = c
+	}
+}
+
+// WithHeader adds a header, such as Authorization, to every request.
+func WithHeader(key, value string) HTTPOption {
+	return func(t *HTTPTransport) {
+		t.header.Add(key, value)
+	}
+}
+
+func NewHTTPTransport(url string, opts ...HTTPOption) *HTTPTransport {
+	t := &HTTPTransport{
+		url:    url,
+		client: http.DefaultClient,
+		header: make(http.Header),
+	}
+
+	for _, o := range opts {
+		o(t)
+	}
+
+	return t
+}
+
+func (t *HTTPTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	hr, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range t.header {
+		hr.Header[k] = v
+	}
+
+	t.mu.Lock()
+	if t.sessionID != "" {
+		hr.Header.Set(sessionHeader, t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		hr.Header.Set(protocolHeader, t.protocolVersion)
+	}
+	t.mu.Unlock()
+
+	return hr, nil
+}
+
+func (t *HTTPTransport) RoundTrip(ctx context.Context, req *Request) (*Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hr, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	hr.Header.Set("Accept", "application/json, text/event-stream")
+
+	hresp, err := t.client.Do(hr)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer hresp.Body.Close()
+
+	if hresp.StatusCode < 200 || hresp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(hresp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %s: %s", hresp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if id := hresp.Header.Get(sessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	if req.IsNotification() {
+		return nil, nil
+	}
+
+	var resp *Response
+	mediaType, _, _ := mime.ParseMediaType(hresp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/event-stream":
+		resp, err = readEventStream(hresp.Body, req.ID)
+	case "application/json":
+		resp = &Response{}
+		err = json.NewDecoder(hresp.Body).Decode(resp)
+	default:
+		err = fmt.Errorf("unexpected content type %q", mediaType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if req.Method == "initialize" && resp.Error == nil {
+		var result InitializeResult
+		if err := json.Unmarshal(resp.Result, &result); err == nil {
+			t.mu.Lock()
+			t.protocolVersion = result.ProtocolVersion
+			t.mu.Unlock()
+		}
+	}
+
+	return resp, nil
+}
+
+// readEventStream reads server-sent events until the response to the request
+// with the given ID arrives. Other messages on the stream are ignored.
+func readEventStream(r io.Reader, id json.RawMessage) (*Response, error) {
+	br := bufio.NewReader(r)
+
+	var data strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteString("\n")
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			var m message
+			if jerr := json.Unmarshal([]byte(data.String()), &m); jerr == nil && !m.isRequest() && bytes.Equal(m.ID, id) {
+				return m.response(), nil
+			}
+			data.Reset()
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("stream ended without a response")
+			}
+			return nil, err
+		}
+	}
+}
+
+// Close ends the session on the server, if one was established.
+func (t *HTTPTransport) Close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+
+	if sessionID == "" {
+		return nil
+	}
+
+	hr, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+
+	hresp, err := t.client.Do(hr)
+	if err != nil {
+		return err
+	}
+	hresp.Body.Close()
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/rhettg/agent"
+)
+
+// imageExtensions gives the preferred extension for common image types, since
+// mime.ExtensionsByType may list a rarely used one first.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// decodeImage converts image content to an agent.Image, naming it so the
+// extension reflects the format.
+func decodeImage(c Content) (agent.Image, error) {
+	data, err := base64.StdEncoding.DecodeString(c.Data)
+	if err != nil {
+		return agent.Image{}, fmt.Errorf("invalid image data: %w", err)
+	}
+
+	ext, ok := imageExtensions[c.MimeType]
+	if !ok {
+		if exts, _ := mime.ExtensionsByType(c.MimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	return agent.Image{Name: "image" + ext, Data: data}, nil
+}
+
+// encodeImage converts an agent.Image to image content. Images referenced by
+// URL are sent as a link in text content.
+func encodeImage(img agent.Image) Content {
+	if len(img.Data) == 0 && img.URL != "" {
+		return Content{Type: "text", Text: "Image: " + img.URL}
+	}
+
+	mimeType := img.ContentType()
+	if mimeType == "" {
+		mimeType = http.DetectContentType(img.Data)
+	}
+
+	return Content{
+		Type:     "image",
+		Data:     base64.StdEncoding.EncodeToString(img.Data),
+		MimeType: mimeType,
+	}
+}
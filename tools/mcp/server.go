@@ -0,0 +1,328 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rhettg/agent"
+	"github.com/rhettg/agent/tools"
+)
+
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// Server exposes a tool set to MCP clients.
+type Server struct {
+	ts           *tools.Tools
+	info         Implementation
+	instructions string
+
+	nextCallID atomic.Int64
+}
+
+type ServerOption func(s *Server)
+
+// WithInstructions sets the instructions sent to clients during
+// initialization.
+func WithInstructions(instructions string) ServerOption {
+	return func(s *Server) {
+		s.instructions = instructions
+	}
+}
+
+func NewServer(name, version string, ts *tools.Tools, opts ...ServerOption) *Server {
+	s := &Server{
+		ts:   ts,
+		info: Implementation{Name: name, Version: version},
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *Response {
+	return &Response{
+		JSONRPC: jsonrpcVersion,
+		ID:      id,
+		Error:   &Error{Code: code, Message: msg},
+	}
+}
+
+// Handle processes a single request. Notifications return a nil response,
+// even when they are invalid, since JSON-RPC never replies to them.
+func (s *Server) Handle(ctx context.Context, req *Request) *Response {
+	if req.IsNotification() {
+		return nil
+	}
+
+	if req.JSONRPC != jsonrpcVersion || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request")
+	}
+
+	var result any
+	var rerr *Error
+	switch req.Method {
+	case "initialize":
+		result, rerr = s.initialize(req.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result, rerr = s.listTools()
+	case "tools/call":
+		result, rerr = s.callTool(ctx, req.Params)
+	default:
+		rerr = &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+
+	resp := &Response{JSONRPC: jsonrpcVersion, ID: req.ID, Error: rerr}
+	if rerr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return errorResponse(req.ID, CodeInternalError, fmt.Sprintf("failed to encode result: %v", err))
+		}
+		resp.Result = data
+	}
+
+	return resp
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, *Error) {
+	var p InitializeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+
+	version := ProtocolVersion
+	for _, v := range supportedVersions {
+		if v == p.ProtocolVersion {
+			version = v
+		}
+	}
+
+	return InitializeResult{
+		ProtocolVersion: version,
+		Capabilities:    map[string]any{"tools": map[string]any{}},
+		ServerInfo:      s.info,
+		Instructions:    s.instructions,
+	}, nil
+}
+
+func (s *Server) listTools() (any, *Error) {
+	defs := s.ts.Defs()
+
+	result := ListToolsResult{Tools: make([]Tool, 0, len(defs))}
+	for _, def := range defs {
+		schema := json.RawMessage(`{"type":"object"}`)
+		if def.Parameters != nil {
+			data, err := json.Marshal(def.Parameters)
+			if err != nil {
+				return nil, &Error{Code: CodeInternalError, Message: fmt.Sprintf("invalid schema for tool %s: %v", def.Name, err)}
+			}
+			schema = data
+		}
+
+		result.Tools = append(result.Tools, Tool{
+			Name:        def.Name,
+			Description: def.Description,
+			InputSchema: schema,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *Server) hasTool(name string) bool {
+	for _, def := range s.ts.Defs() {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *Error) {
+	var p CallToolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+
+	if !s.hasTool(p.Name) {
+		return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", p.Name)}
+	}
+
+	arguments := "{}"
+	if len(p.Arguments) > 0 && string(p.Arguments) != "null" {
+		arguments = string(p.Arguments)
+	}
+
+	toolCall := agent.ToolCall{
+		ID:        fmt.Sprintf("mcp_%d", s.nextCallID.Add(1)),
+		Name:      p.Name,
+		Arguments: arguments,
+	}
+
+	m, err := s.ts.Call(ctx, toolCall)
+	if err != nil {
+		// Failures of the tool itself are reported in the result so the
+		// client's model can see them.
+		return CallToolResult{
+			Content: []Content{{Type: "text", Text: err.Error()}},
+			IsError: true,
+		}, nil
+	}
+
+	content, err := m.Content(ctx)
+	if err != nil {
+		return nil, &Error{Code: CodeInternalError, Message: fmt.Sprintf("failed to get tool result: %v", err)}
+	}
+
+	result := CallToolResult{
+		Content: []Content{{Type: "text", Text: content}},
+		IsError: m.HasTag(tools.ErrorTag) || m.HasTag(tools.DeniedTag),
+	}
+	for _, img := range m.Images() {
+		img, err := img.Resolve(ctx)
+		if err != nil {
+			return nil, &Error{Code: CodeInternalError, Message: fmt.Sprintf("failed to get tool result: %v", err)}
+		}
+		result.Content = append(result.Content, encodeImage(img))
+	}
+
+	return result, nil
+}
+
+// handleMessage decodes and handles a raw message, returning the encoded
+// response or nil if there is nothing to send.
+func (s *Server) handleMessage(ctx context.Context, data []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse(nil, CodeParseError, "parse error")
+		}
+		return errorResponse(nil, CodeInvalidRequest, "invalid request")
+	}
+
+	return s.Handle(ctx, &req)
+}
+
+// Serve handles newline delimited JSON-RPC messages from r, writing responses
+// to w, until r is exhausted or ctx is canceled. This is the stdio transport
+// when used with os.Stdin and os.Stdout. Blank lines are ignored.
+//
+// If writing a response fails, Serve stops and returns the write error.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// writeErr is the first failed write, after which nothing more is
+	// written.
+	var wMu sync.Mutex
+	var writeErr error
+	write := func(resp *Response) {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			data, _ = json.Marshal(errorResponse(resp.ID, CodeInternalError, "failed to encode response"))
+		}
+
+		wMu.Lock()
+		defer wMu.Unlock()
+		if writeErr != nil {
+			return
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			writeErr = err
+			cancel()
+		}
+	}
+	failed := func() error {
+		wMu.Lock()
+		defer wMu.Unlock()
+		return writeErr
+	}
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	// Requests are handled concurrently so a slow tool doesn't block pings
+	// or other calls.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := failed(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				// Responses still being written may fail too.
+				wg.Wait()
+				return failed()
+			}
+			return err
+		case line := <-lines:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := s.handleMessage(ctx, line); resp != nil {
+					write(resp)
+				}
+			}()
+		}
+	}
+}
+
+// ServeHTTP implements the streamable HTTP transport without sessions. Each
+// POSTed request is answered with a JSON response.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	resp := s.handleMessage(r.Context(), data)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,217 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/rhettg/agent/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greetArgs struct {
+	Name string `json:"name" description:"who to greet"`
+}
+
+var pngData = []byte("\x89PNG\r\n\x1a\nfake")
+
+func newTestTools(t *testing.T) *tools.Tools {
+	ts := tools.New()
+
+	err := tools.AddFunc(ts, "greet", "Greet someone", func(ctx context.Context, args greetArgs) (string, error) {
+		return "Hello " + args.Name, nil
+	})
+	require.NoError(t, err)
+
+	err = tools.AddFunc(ts, "fail", "Always fails", func(ctx context.Context, args struct{}) (string, error) {
+		return "", errors.New("it broke")
+	})
+	require.NoError(t, err)
+
+	err = tools.AddFunc(ts, "chart", "Draws a chart", func(ctx context.Context, args struct{}) (*tools.Result, error) {
+		return tools.NewResult(tools.Text("A chart"), tools.Image("chart.png", pngData)), nil
+	})
+	require.NoError(t, err)
+
+	return ts
+}
+
+func TestServerHandle(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer("test", "1.0", newTestTools(t))
+
+	call := func(method string, params string) *Response {
+		req := &Request{JSONRPC: jsonrpcVersion, ID: json.RawMessage(`1`), Method: method}
+		if params != "" {
+			req.Params = json.RawMessage(params)
+		}
+		return s.Handle(ctx, req)
+	}
+
+	resp := call("initialize", `{"protocolVersion": "2024-11-05", "capabilities": {}, "clientInfo": {"name": "c", "version": "1"}}`)
+	require.Nil(t, resp.Error)
+	var init InitializeResult
+	require.NoError(t, json.Unmarshal(resp.Result, &init))
+	assert.Equal(t, "2024-11-05", init.ProtocolVersion)
+	assert.Equal(t, "test", init.ServerInfo.Name)
+
+	assert.Nil(t, s.Handle(ctx, &Request{JSONRPC: jsonrpcVersion, Method: "notifications/initialized"}))
+
+	resp = call("tools/list", "")
+	require.Nil(t, resp.Error)
+	var list ListToolsResult
+	require.NoError(t, json.Unmarshal(resp.Result, &list))
+	require.Len(t, list.Tools, 3)
+	assert.Equal(t, "greet", list.Tools[0].Name)
+	assert.JSONEq(t, `{"type":"object","properties":{"name":{"type":"string","description":"who to greet"}},"required":["name"],"additionalProperties":false}`, string(list.Tools[0].InputSchema))
+
+	resp = call("tools/call", `{"name": "greet", "arguments": {"name": "Bob"}}`)
+	require.Nil(t, resp.Error)
+	var result CallToolResult
+	require.NoError(t, json.Unmarshal(resp.Result, &result))
+	assert.Equal(t, CallToolResult{Content: []Content{{Type: "text", Text: "Hello Bob"}}}, result)
+
+	// Invalid arguments are reported to the model, not as protocol errors.
+	resp = call("tools/call", `{"name": "greet", "arguments": {}}`)
+	require.Nil(t, resp.Error)
+	result = CallToolResult{}
+	require.NoError(t, json.Unmarshal(resp.Result, &result))
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].Text, "name: missing required property")
+
+	resp = call("tools/call", `{"name": "fail"}`)
+	require.Nil(t, resp.Error)
+	result = CallToolResult{}
+	require.NoError(t, json.Unmarshal(resp.Result, &result))
+	assert.Equal(t, CallToolResult{Content: []Content{{Type: "text", Text: "it broke"}}, IsError: true}, result)
+
+	resp = call("tools/call", `{"name": "chart"}`)
+	require.Nil(t, resp.Error)
+	result = CallToolResult{}
+	require.NoError(t, json.Unmarshal(resp.Result, &result))
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, Content{Type: "image", Data: "iVBORw0KGgpmYWtl", MimeType: "image/png"}, result.Content[1])
+
+	resp = call("tools/call", `{"name": "missing"}`)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeInvalidParams, resp.Error.Code)
+
+	resp = call("tools/call", `[]`)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeInvalidParams, resp.Error.Code)
+
+	resp = call("resources/list", "")
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeMethodNotFound, resp.Error.Code)
+
+	resp = s.Handle(ctx, &Request{ID: json.RawMessage(`2`), Method: "ping"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeInvalidRequest, resp.Error.Code)
+}
+
+func TestServerServe(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer("test", "1.0", newTestTools(t))
+
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, sr, sw)
+		sw.Close()
+	}()
+
+	// Malformed input gets a parse error.
+	_, err := cw.Write([]byte("{not json\n"))
+	require.NoError(t, err)
+
+	br := bufio.NewReader(cr)
+	line, err := br.ReadBytes('\n')
+	require.NoError(t, err)
+	var resp Response
+	require.NoError(t, json.Unmarshal(line, &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeParseError, resp.Error.Code)
+	assert.Equal(t, "null", string(resp.ID))
+
+	// The remaining output goes to the client.
+	c, err := Connect(ctx, NewStdioTransport(br, cw))
+	require.NoError(t, err)
+
+	ts, err := c.Tools(ctx)
+	require.NoError(t, err)
+
+	m, err := ts.Call(ctx, agent.ToolCall{ID: "1", Name: "greet", Arguments: `{"name": "Alice"}`})
+	require.NoError(t, err)
+	content, _ := m.Content(ctx)
+	assert.Equal(t, "Hello Alice", content)
+
+	// Images survive the round trip.
+	m, err = ts.Call(ctx, agent.ToolCall{ID: "2", Name: "chart"})
+	require.NoError(t, err)
+	content, _ = m.Content(ctx)
+	assert.Equal(t, "A chart\n[image: image/png]", content)
+	assert.Equal(t, []agent.Image{{Name: "image.png", Data: pngData}}, m.Images())
+
+	require.NoError(t, c.Close())
+	require.NoError(t, <-done)
+}
+
+func TestServerHTTP(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(NewServer("test", "1.0", newTestTools(t)))
+	defer srv.Close()
+
+	c, err := Connect(ctx, NewHTTPTransport(srv.URL))
+	require.NoError(t, err)
+	defer c.Close()
+
+	result, err := c.CallTool(ctx, "greet", json.RawMessage(`{"name": "Carol"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Carol", result.Content[0].Text)
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestServerServeWriteError(t *testing.T) {
+	s := NewServer("test", "1.0", newTestTools(t))
+
+	in := strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n")
+	err := s.Serve(context.Background(), in, failingWriter{})
+	assert.EqualError(t, err, "disk full")
+}
+
+func TestServerServeNotifications(t *testing.T) {
+	s := NewServer("test", "1.0", newTestTools(t))
+
+	in := strings.NewReader(strings.Join([]string{
+		``,
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+		`   `,
+		`{"jsonrpc": "1.0", "method": "notifications/bad"}`,
+		`{"method": ""}`,
+		`{"jsonrpc": "2.0", "id": 7, "method": "ping"}`,
+		``,
+	}, "\n"))
+
+	var out strings.Builder
+	require.NoError(t, s.Serve(context.Background(), in, &out))
+
+	// Only the ping is answered: blank lines are skipped and notifications,
+	// valid or not, get no reply.
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 7, "result": {}}`, out.String())
+}
@@ -0,0 +1,214 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Transport exchanges JSON-RPC messages with an MCP server.
+type Transport interface {
+	// RoundTrip sends a request and waits for its response. Notifications
+	// return a nil response.
+	RoundTrip(ctx context.Context, req *Request) (*Response, error)
+
+	Close() error
+}
+
+// ErrClosed is returned when using a transport that has been closed.
+var ErrClosed = errors.New("transport closed")
+
+// StdioTransport speaks newline delimited JSON-RPC over a pair of streams,
+// normally the stdin and stdout of a server process.
+type StdioTransport struct {
+	w   io.WriteCloser
+	wMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *Response
+	done    chan struct{}
+	err     error
+
+	cmd *exec.Cmd
+}
+
+// NewStdioTransport reads messages from r and writes them to w.
+func NewStdioTransport(r io.Reader, w io.WriteCloser) *StdioTransport {
+	t := &StdioTransport{
+		w:       w,
+		pending: make(map[string]chan *Response),
+		done:    make(chan struct{}),
+	}
+
+	go t.readLoop(r)
+
+	return t
+}
+
+// NewCommandTransport starts cmd and communicates with it over its stdin and
+// stdout. The process is stopped when the transport is closed.
+func NewCommandTransport(cmd *exec.Cmd) (*StdioTransport, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
+
+	t := NewStdioTransport(stdout, stdin)
+	t.cmd = cmd
+
+	return t, nil
+}
+
+func (t *StdioTransport) readLoop(r io.Reader) {
+	br := bufio.NewReader(r)
+
+	var err error
+	for {
+		var line []byte
+		line, err = br.ReadBytes('\n')
+		if len(line) > 0 {
+			t.handle(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = ErrClosed
+	}
+
+	t.mu.Lock()
+	t.err = err
+	close(t.done)
+	t.mu.Unlock()
+}
+
+func (t *StdioTransport) handle(line []byte) {
+	var m message
+	if err := json.Unmarshal(line, &m); err != nil {
+		// Servers may log to stdout by mistake. There is nobody to report
+		// the error to, so skip it.
+		return
+	}
+
+	if m.isRequest() {
+		if len(m.ID) > 0 {
+			go t.reply(m.request())
+		}
+		return
+	}
+
+	t.mu.Lock()
+	ch, ok := t.pending[string(m.ID)]
+	delete(t.pending, string(m.ID))
+	t.mu.Unlock()
+
+	if ok {
+		ch <- m.response()
+	}
+}
+
+// reply answers requests made by the server. Only ping is supported.
+func (t *StdioTransport) reply(req *Request) {
+	resp := &Response{JSONRPC: jsonrpcVersion, ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+
+	// If this fails the transport is broken and pending calls will see it.
+	_ = t.write(resp)
+}
+
+func (t *StdioTransport) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	t.wMu.Lock()
+	defer t.wMu.Unlock()
+
+	_, err = t.w.Write(append(data, '\n'))
+	return err
+}
+
+func (t *StdioTransport) RoundTrip(ctx context.Context, req *Request) (*Response, error) {
+	var ch chan *Response
+	if !req.IsNotification() {
+		ch = make(chan *Response, 1)
+
+		t.mu.Lock()
+		select {
+		case <-t.done:
+			t.mu.Unlock()
+			return nil, t.err
+		default:
+		}
+		t.pending[string(req.ID)] = ch
+		t.mu.Unlock()
+	}
+
+	if err := t.write(req); err != nil {
+		t.forget(req.ID)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if ch == nil {
+		return nil, nil
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		t.forget(req.ID)
+		return nil, t.err
+	case <-ctx.Done():
+		t.forget(req.ID)
+		return nil, ctx.Err()
+	}
+}
+
+func (t *StdioTransport) forget(id json.RawMessage) {
+	t.mu.Lock()
+	delete(t.pending, string(id))
+	t.mu.Unlock()
+}
+
+// Close closes the connection. If the transport started a process, it is
+// given a moment to exit before being killed.
+func (t *StdioTransport) Close() error {
+	err := t.w.Close()
+
+	if t.cmd != nil {
+		exited := make(chan error, 1)
+		go func() { exited <- t.cmd.Wait() }()
+
+		select {
+		case <-exited:
+		case <-time.After(2 * time.Second):
+			_ = t.cmd.Process.Kill()
+			<-exited
+		}
+	}
+
+	return err
+}
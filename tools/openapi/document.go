@@ -0,0 +1,276 @@
+// Package openapi generates tools from an OpenAPI 3 document.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Document is the subset of an OpenAPI 3 document needed to call its
+// operations.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Servers    []Server            `json:"servers"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Server struct {
+	URL       string                    `json:"url"`
+	Variables map[string]ServerVariable `json:"variables"`
+}
+
+type ServerVariable struct {
+	Default string `json:"default"`
+}
+
+type PathItem struct {
+	Parameters []Parameter `json:"parameters"`
+
+	Get     *Operation `json:"get"`
+	Put     *Operation `json:"put"`
+	Post    *Operation `json:"post"`
+	Delete  *Operation `json:"delete"`
+	Patch   *Operation `json:"patch"`
+	Head    *Operation `json:"head"`
+	Options *Operation `json:"options"`
+}
+
+type methodOperation struct {
+	method string
+	op     *Operation
+}
+
+// operations returns the operations of the path in a stable order.
+func (p PathItem) operations() []methodOperation {
+	all := []methodOperation{
+		{"GET", p.Get}, {"PUT", p.Put}, {"POST", p.Post}, {"DELETE", p.Delete},
+		{"PATCH", p.Patch}, {"HEAD", p.Head}, {"OPTIONS", p.Options},
+	}
+
+	ops := all[:0]
+	for _, o := range all {
+		if o.op != nil {
+			ops = append(ops, o)
+		}
+	}
+	return ops
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary"`
+	Description string       `json:"description"`
+	Parameters  []Parameter  `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+type Parameter struct {
+	Ref         string         `json:"$ref"`
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description"`
+	Required    bool           `json:"required"`
+	Schema      map[string]any `json:"schema"`
+}
+
+type RequestBody struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Required    bool                 `json:"required"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema map[string]any `json:"schema"`
+}
+
+type Components struct {
+	Schemas       map[string]any         `json:"schemas"`
+	Parameters    map[string]Parameter   `json:"parameters"`
+	RequestBodies map[string]RequestBody `json:"requestBodies"`
+}
+
+// Parse reads an OpenAPI 3 document in JSON or YAML format.
+func Parse(data []byte) (*Document, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var raw any
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("error parsing document: %w", err)
+		}
+
+		// YAML maps have interface{} keys, which JSON can't represent.
+		var err error
+		data, err = json.Marshal(jsonCompatible(raw))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing document: %w", err)
+		}
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("error parsing document: %w", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", doc.OpenAPI)
+	}
+
+	return doc, nil
+}
+
+func jsonCompatible(v any) any {
+	switch val := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return m
+	case []any:
+		l := make([]any, len(val))
+		for i, item := range val {
+			l[i] = jsonCompatible(item)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// resolveSchema returns a copy of the schema with local references to
+// component schemas inlined.
+func (d *Document) resolveSchema(s any) (any, error) {
+	r := &schemaResolver{
+		doc:       d,
+		resolving: make(map[string]bool),
+		resolved:  make(map[string]any),
+	}
+	v, _, err := r.inline(s)
+	return v, err
+}
+
+// schemaResolver inlines references, tracking those being resolved. A
+// reference that recurs within itself can't be inlined, so it is described
+// instead. Each reference is expanded once and the result reused wherever it
+// appears, so schemas that refer to the same component along many paths
+// don't take exponential time to resolve.
+type schemaResolver struct {
+	doc       *Document
+	resolving map[string]bool
+	resolved  map[string]any
+}
+
+// inline returns s with references inlined. It reports whether a recursive
+// reference was cut short, in which case the result depends on where it was
+// reached and isn't reused.
+func (r *schemaResolver) inline(s any) (any, bool, error) {
+	switch val := s.(type) {
+	case map[string]any:
+		if ref, ok := val["$ref"].(string); ok {
+			if v, ok := r.resolved[ref]; ok {
+				return v, false, nil
+			}
+			if r.resolving[ref] {
+				return map[string]any{"description": "recursive reference to " + ref}, true, nil
+			}
+
+			target, err := r.doc.lookupSchema(ref)
+			if err != nil {
+				return nil, false, err
+			}
+
+			r.resolving[ref] = true
+			v, cut, err := r.inline(target)
+			delete(r.resolving, ref)
+			if err == nil && !cut {
+				r.resolved[ref] = v
+			}
+			return v, cut, err
+		}
+
+		m := make(map[string]any, len(val))
+		cut := false
+		for k, item := range val {
+			v, c, err := r.inline(item)
+			if err != nil {
+				return nil, false, err
+			}
+			m[k] = v
+			cut = cut || c
+		}
+		return m, cut, nil
+	case []any:
+		l := make([]any, len(val))
+		cut := false
+		for i, item := range val {
+			v, c, err := r.inline(item)
+			if err != nil {
+				return nil, false, err
+			}
+			l[i] = v
+			cut = cut || c
+		}
+		return l, cut, nil
+	default:
+		return s, false, nil
+	}
+}
+
+func refName(ref, prefix string) (string, error) {
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported reference %q", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+func (d *Document) lookupSchema(ref string) (any, error) {
+	name, err := refName(ref, "#/components/schemas/")
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := d.Components.Schemas[name]
+	if !ok {
+		return nil, fmt.Errorf("schema not found: %s", ref)
+	}
+	return s, nil
+}
+
+func (d *Document) resolveParameter(p Parameter) (Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+
+	name, err := refName(p.Ref, "#/components/parameters/")
+	if err != nil {
+		return p, err
+	}
+
+	rp, ok := d.Components.Parameters[name]
+	if !ok {
+		return p, fmt.Errorf("parameter not found: %s", p.Ref)
+	}
+	return rp, nil
+}
+
+func (d *Document) resolveRequestBody(b *RequestBody) (*RequestBody, error) {
+	if b == nil || b.Ref == "" {
+		return b, nil
+	}
+
+	name, err := refName(b.Ref, "#/components/requestBodies/")
+	if err != nil {
+		return nil, err
+	}
+
+	rb, ok := d.Components.RequestBodies[name]
+	if !ok {
+		return nil, fmt.Errorf("request body not found: %s", b.Ref)
+	}
+	return &rb, nil
+}
@@ -0,0 +1,440 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rhettg/agent"
+	"github.com/rhettg/agent/tools"
+)
+
+type config struct {
+	baseURL    string
+	client     *http.Client
+	header     http.Header
+	operations map[string]bool
+	editor     func(*http.Request) error
+	maxBody    int64
+}
+
+type Option func(c *config)
+
+// WithBaseURL sets the URL operation paths are relative to, overriding the
+// servers listed in the document.
+func WithBaseURL(u string) Option {
+	return func(c *config) {
+		c.baseURL = u
+	}
+}
+
+// WithHTTPClient sets the client used for requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithHeader adds a header, such as Authorization, to every request.
+func WithHeader(key, value string) Option {
+	return func(c *config) {
+		c.header.Add(key, value)
+	}
+}
+
+// DefaultMaxResponseBytes is how much of a response body is read when
+// WithMaxResponseBytes isn't used.
+const DefaultMaxResponseBytes = 1 << 20
+
+// WithMaxResponseBytes limits how much of a response body is read and
+// returned to the model. Longer bodies are cut off with a note.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *config) {
+		c.maxBody = n
+	}
+}
+
+// WithOperations limits the tools to the named operations. Names are
+// operation IDs or, for operations without one, the generated tool name.
+func WithOperations(names ...string) Option {
+	return func(c *config) {
+		if c.operations == nil {
+			c.operations = make(map[string]bool)
+		}
+		for _, n := range names {
+			c.operations[n] = true
+		}
+	}
+}
+
+// WithRequestEditor sets a function called on every request before it is
+// sent, for example to sign it.
+func WithRequestEditor(fn func(*http.Request) error) Option {
+	return func(c *config) {
+		c.editor = fn
+	}
+}
+
+// bodyProperty is the argument holding the request body.
+const bodyProperty = "body"
+
+// operation is everything needed to call an operation.
+type operation struct {
+	method     string
+	path       string
+	parameters []Parameter
+	hasBody    bool
+}
+
+// Tools returns a tool set with one tool per operation in the document.
+// Operations whose request body can't be sent as JSON are skipped.
+func Tools(doc *Document, opts ...Option) (*tools.Tools, error) {
+	c := &config{
+		client:  http.DefaultClient,
+		header:  make(http.Header),
+		maxBody: DefaultMaxResponseBytes,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	baseURL, err := c.resolveBaseURL(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	ts := tools.New()
+	found := make(map[string]bool)
+	for _, p := range paths {
+		item := doc.Paths[p]
+		for _, mo := range item.operations() {
+			name := toolName(mo.method, p, mo.op)
+			if c.operations != nil && !c.operations[mo.op.OperationID] && !c.operations[name] {
+				continue
+			}
+			found[mo.op.OperationID] = true
+			found[name] = true
+
+			op, schema, ok, err := doc.buildOperation(mo.method, p, item, mo.op)
+			if err != nil {
+				return nil, fmt.Errorf("invalid operation %s %s: %w", mo.method, p, err)
+			}
+			if !ok {
+				continue
+			}
+
+			if err := ts.Add(name, description(mo.op), schema, c.tool(baseURL, op)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for n := range c.operations {
+		if !found[n] {
+			return nil, fmt.Errorf("operation not found: %s", n)
+		}
+	}
+
+	return ts, nil
+}
+
+func (c *config) resolveBaseURL(doc *Document) (string, error) {
+	base := c.baseURL
+	if base == "" && len(doc.Servers) > 0 {
+		s := doc.Servers[0]
+		base = s.URL
+		for k, v := range s.Variables {
+			base = strings.ReplaceAll(base, "{"+k+"}", v.Default)
+		}
+	}
+
+	u, err := url.Parse(base)
+	if err != nil || !u.IsAbs() {
+		return "", fmt.Errorf("no absolute server URL in document, use WithBaseURL")
+	}
+
+	return strings.TrimSuffix(base, "/"), nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// toolName returns the operation ID, or a name derived from the method and
+// path, restricted to the characters providers accept.
+func toolName(method, path string, op *Operation) string {
+	name := op.OperationID
+	if name == "" {
+		name = strings.ToLower(method) + "_" + path
+	}
+
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func description(op *Operation) string {
+	switch {
+	case op.Summary != "" && op.Description != "":
+		return op.Summary + "\n\n" + op.Description
+	case op.Summary != "":
+		return op.Summary
+	default:
+		return op.Description
+	}
+}
+
+// jsonMediaType returns the JSON media type of the body, if it has one.
+func jsonMediaType(b *RequestBody) (MediaType, bool) {
+	keys := make([]string, 0, len(b.Content))
+	for k := range b.Content {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "application/json" || strings.HasSuffix(k, "+json") {
+			return b.Content[k], true
+		}
+	}
+	return MediaType{}, false
+}
+
+// buildOperation merges path and operation parameters and builds the
+// parameters schema. It reports false if the operation can't be called.
+func (d *Document) buildOperation(method, path string, item PathItem, op *Operation) (*operation, map[string]any, bool, error) {
+	var params []Parameter
+	index := make(map[string]int)
+	for _, p := range append(append([]Parameter{}, item.Parameters...), op.Parameters...) {
+		rp, err := d.resolveParameter(p)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if rp.In == "cookie" {
+			continue
+		}
+
+		// Operation parameters override path parameters of the same name.
+		key := rp.In + ":" + rp.Name
+		if i, ok := index[key]; ok {
+			params[i] = rp
+			continue
+		}
+		index[key] = len(params)
+		params = append(params, rp)
+	}
+
+	properties := make(map[string]any)
+	required := []string{}
+	for _, p := range params {
+		if _, ok := properties[p.Name]; ok {
+			return nil, nil, false, fmt.Errorf("duplicate parameter %s", p.Name)
+		}
+
+		s, err := d.resolveSchema(p.Schema)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		schema, _ := s.(map[string]any)
+		if len(schema) == 0 {
+			schema = map[string]any{"type": "string"}
+		}
+		if p.Description != "" {
+			schema["description"] = p.Description
+		}
+
+		properties[p.Name] = schema
+		if p.Required || p.In == "path" {
+			required = append(required, p.Name)
+		}
+	}
+
+	rb, err := d.resolveRequestBody(op.RequestBody)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	hasBody := rb != nil && len(rb.Content) > 0
+	if hasBody {
+		mt, ok := jsonMediaType(rb)
+		if !ok {
+			return nil, nil, false, nil
+		}
+
+		if _, ok := properties[bodyProperty]; ok {
+			return nil, nil, false, fmt.Errorf("parameter %s conflicts with the request body", bodyProperty)
+		}
+
+		s, err := d.resolveSchema(mt.Schema)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		schema, _ := s.(map[string]any)
+		if schema == nil {
+			schema = map[string]any{}
+		}
+		if rb.Description != "" {
+			schema["description"] = rb.Description
+		}
+
+		properties[bodyProperty] = schema
+		if rb.Required {
+			required = append(required, bodyProperty)
+		}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+
+	return &operation{method: method, path: path, parameters: params, hasBody: hasBody}, schema, true, nil
+}
+
+// formatValue renders an argument as it appears in a path, query or header.
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+func (c *config) tool(baseURL string, op *operation) agent.Tool {
+	return func(ctx context.Context, arguments string) (string, error) {
+		args := make(map[string]any)
+		if strings.TrimSpace(arguments) != "" {
+			dec := json.NewDecoder(strings.NewReader(arguments))
+			dec.UseNumber()
+			if err := dec.Decode(&args); err != nil {
+				return "", fmt.Errorf("could not parse arguments as JSON: %w", err)
+			}
+		}
+
+		req, err := c.newRequest(ctx, baseURL, op, args)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("error making request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBody+1))
+		if err != nil {
+			return "", fmt.Errorf("error reading response: %w", err)
+		}
+
+		truncated := ""
+		if int64(len(body)) > c.maxBody {
+			body = body[:c.maxBody]
+			truncated = fmt.Sprintf("\n\n[response truncated after %d bytes]", c.maxBody)
+		}
+
+		// Error statuses are returned to the model rather than failing the
+		// call, since they usually explain what was wrong with the request.
+		return fmt.Sprintf("HTTP %s\n\n%s%s", resp.Status, body, truncated), nil
+	}
+}
+
+func (c *config) newRequest(ctx context.Context, baseURL string, op *operation, args map[string]any) (*http.Request, error) {
+	path := op.path
+	query := url.Values{}
+	header := make(http.Header)
+
+	for _, p := range op.parameters {
+		v, ok := args[p.Name]
+		if !ok || v == nil {
+			if p.In == "path" {
+				return nil, fmt.Errorf("missing path parameter %s", p.Name)
+			}
+			continue
+		}
+
+		switch p.In {
+		case "path":
+			// Escaping leaves dot segments alone, which would let the model
+			// reach other endpoints on the host.
+			pv := formatValue(v)
+			if pv == "" || pv == "." || pv == ".." {
+				return nil, fmt.Errorf("invalid path parameter %s: %q", p.Name, pv)
+			}
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(pv))
+		case "query":
+			if l, ok := v.([]any); ok {
+				for _, item := range l {
+					query.Add(p.Name, formatValue(item))
+				}
+			} else {
+				query.Set(p.Name, formatValue(v))
+			}
+		case "header":
+			header.Set(p.Name, formatValue(v))
+		}
+	}
+
+	u := baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if b, ok := args[bodyProperty]; ok && op.hasBody {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode body: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, op.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	// Configured headers are applied last so a header parameter can't
+	// replace credentials.
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.editor != nil {
+		if err := c.editor(req); err != nil {
+			return nil, fmt.Errorf("failed to edit request: %w", err)
+		}
+	}
+
+	return req, nil
+}
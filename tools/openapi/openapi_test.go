@@ -0,0 +1,321 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://{region}.example.com/v1
+    variables:
+      region:
+        default: us
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+        - name: tag
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+    post:
+      operationId: createPet
+      summary: Create a pet
+      requestBody:
+        $ref: '#/components/requestBodies/Pet'
+  /pets/{petId}:
+    parameters:
+      - $ref: '#/components/parameters/PetID'
+    get:
+      summary: Get a pet
+      parameters:
+        - name: X-Request-ID
+          in: header
+          schema:
+            type: string
+    delete:
+      operationId: deletePet
+  /pets/{petId}/photo:
+    put:
+      operationId: uploadPhoto
+      parameters:
+        - $ref: '#/components/parameters/PetID'
+      requestBody:
+        content:
+          image/png:
+            schema:
+              type: string
+              format: binary
+components:
+  parameters:
+    PetID:
+      name: petId
+      in: path
+      required: true
+      description: The pet's ID
+      schema:
+        type: string
+  requestBodies:
+    Pet:
+      required: true
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Pet'
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        tag:
+          type: string
+`
+
+func parsePetstore(t *testing.T) *Document {
+	doc, err := Parse([]byte(petstore))
+	require.NoError(t, err)
+	return doc
+}
+
+func TestParse(t *testing.T) {
+	doc := parsePetstore(t)
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Len(t, doc.Paths, 3)
+	assert.Equal(t, "listPets", doc.Paths["/pets"].Get.OperationID)
+
+	_, err := Parse([]byte(`{"openapi": "3.1.0", "paths": {}}`))
+	assert.NoError(t, err)
+
+	_, err = Parse([]byte(`{"swagger": "2.0"}`))
+	assert.Error(t, err)
+}
+
+func TestTools(t *testing.T) {
+	ts, err := Tools(parsePetstore(t))
+	require.NoError(t, err)
+
+	defs := make(map[string]agent.ToolDef)
+	for _, def := range ts.Defs() {
+		defs[def.Name] = def
+	}
+
+	// uploadPhoto only accepts an image body.
+	assert.Len(t, defs, 4)
+	assert.Contains(t, defs, "get_pets_petId")
+
+	params := defs["createPet"].Parameters.(map[string]any)
+	assert.Equal(t, []string{"body"}, params["required"])
+	body := params["properties"].(map[string]any)["body"].(map[string]any)
+	assert.Equal(t, "object", body["type"])
+	assert.Contains(t, body["properties"], "name")
+
+	params = defs["get_pets_petId"].Parameters.(map[string]any)
+	assert.Equal(t, []string{"petId"}, params["required"])
+	assert.Contains(t, params["properties"], "X-Request-ID")
+	assert.Equal(t, "Get a pet", defs["get_pets_petId"].Description)
+}
+
+func TestToolsOperations(t *testing.T) {
+	ts, err := Tools(parsePetstore(t), WithOperations("listPets", "get_pets_petId"))
+	require.NoError(t, err)
+	assert.Len(t, ts.Defs(), 2)
+	assert.True(t, ts.Has("listPets"))
+	assert.False(t, ts.Has("createPet"))
+
+	_, err = Tools(parsePetstore(t), WithOperations("missing"))
+	assert.EqualError(t, err, "operation not found: missing")
+}
+
+func TestToolsBaseURL(t *testing.T) {
+	doc := parsePetstore(t)
+
+	c := &config{}
+	u, err := c.resolveBaseURL(doc)
+	require.NoError(t, err)
+	assert.Equal(t, "https://us.example.com/v1", u)
+
+	doc.Servers = []Server{{URL: "/v1"}}
+	_, err = Tools(doc)
+	assert.Error(t, err)
+}
+
+type recorded struct {
+	method string
+	path   string
+	query  string
+	header http.Header
+	body   string
+}
+
+func TestToolCall(t *testing.T) {
+	var got recorded
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = recorded{r.Method, r.URL.EscapedPath(), r.URL.RawQuery, r.Header, string(body)}
+
+		if r.URL.Path == "/v1/pets/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": "not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	ts, err := Tools(parsePetstore(t),
+		WithBaseURL(srv.URL+"/v1/"),
+		WithHeader("Authorization", "Bearer secret"),
+		WithRequestEditor(func(r *http.Request) error {
+			r.Header.Set("X-Signed", "yes")
+			return nil
+		}))
+	require.NoError(t, err)
+
+	call := func(name, args string) string {
+		m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: name, Arguments: args})
+		require.NoError(t, err)
+		content, err := m.Content(context.Background())
+		require.NoError(t, err)
+		return content
+	}
+
+	out := call("listPets", `{"limit": 1000000, "tag": ["a", "b"]}`)
+	assert.Equal(t, "HTTP 200 OK\n\n{\"ok\": true}", out)
+	assert.Equal(t, "GET", got.method)
+	assert.Equal(t, "/v1/pets", got.path)
+	assert.Equal(t, "limit=1000000&tag=a&tag=b", got.query)
+	assert.Equal(t, "Bearer secret", got.header.Get("Authorization"))
+	assert.Equal(t, "yes", got.header.Get("X-Signed"))
+
+	call("createPet", `{"body": {"name": "Rex"}}`)
+	assert.Equal(t, "POST", got.method)
+	assert.Equal(t, "application/json", got.header.Get("Content-Type"))
+	var pet map[string]any
+	require.NoError(t, json.Unmarshal([]byte(got.body), &pet))
+	assert.Equal(t, map[string]any{"name": "Rex"}, pet)
+
+	call("get_pets_petId", `{"petId": "a b", "X-Request-ID": "r1"}`)
+	assert.Equal(t, "/v1/pets/a%20b", got.path)
+	assert.Equal(t, "r1", got.header.Get("X-Request-ID"))
+
+	out = call("deletePet", `{"petId": "missing"}`)
+	assert.Equal(t, "DELETE", got.method)
+	assert.Equal(t, "HTTP 404 Not Found\n\n{\"error\": \"not found\"}", out)
+
+	// Validation catches the missing path parameter before a request is made.
+	got = recorded{}
+	out = call("deletePet", `{}`)
+	assert.Contains(t, out, "petId")
+	assert.Empty(t, got.method)
+
+	// Dot segments would reach other endpoints with the same credentials.
+	for _, id := range []string{"..", ".", ""} {
+		_, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "deletePet", Arguments: fmt.Sprintf(`{"petId": %q}`, id)})
+		assert.ErrorContains(t, err, "invalid path parameter petId", id)
+		assert.Empty(t, got.method, id)
+	}
+}
+
+func TestResolveSchemaRecursive(t *testing.T) {
+	doc, err := Parse([]byte(`
+openapi: 3.0.3
+info: {title: Tree, version: 1.0.0}
+paths: {}
+components:
+  schemas:
+    Node:
+      type: object
+      properties:
+        left: {$ref: '#/components/schemas/Node'}
+        right: {$ref: '#/components/schemas/Node'}
+`))
+	require.NoError(t, err)
+
+	s, err := doc.resolveSchema(map[string]any{"$ref": "#/components/schemas/Node"})
+	require.NoError(t, err)
+
+	props := s.(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"description": "recursive reference to #/components/schemas/Node"}, props["left"])
+	assert.Equal(t, props["left"], props["right"])
+}
+
+func TestResolveSchemaShared(t *testing.T) {
+	// Each level refers to the next twice, so inlining without reuse would
+	// visit 2^40 schemas.
+	var b strings.Builder
+	b.WriteString("openapi: 3.0.3\ninfo: {title: Diamond, version: 1.0.0}\npaths: {}\ncomponents:\n  schemas:\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&b, "    L%d: {type: object, properties: {a: {$ref: '#/components/schemas/L%d'}, b: {$ref: '#/components/schemas/L%d'}}}\n", i, i+1, i+1)
+	}
+	b.WriteString("    L40: {type: string}\n")
+
+	doc, err := Parse([]byte(b.String()))
+	require.NoError(t, err)
+
+	s, err := doc.resolveSchema(map[string]any{"$ref": "#/components/schemas/L0"})
+	require.NoError(t, err)
+
+	props := s.(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, "object", props["a"].(map[string]any)["type"])
+}
+
+func TestToolCallLimits(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+
+	doc, err := Parse([]byte(`
+openapi: 3.0.3
+info: {title: Auth, version: 1.0.0}
+paths:
+  /items:
+    get:
+      operationId: listItems
+      parameters:
+        - {name: Authorization, in: header, schema: {type: string}}
+`))
+	require.NoError(t, err)
+
+	ts, err := Tools(doc,
+		WithBaseURL(srv.URL),
+		WithHeader("Authorization", "Bearer secret"),
+		WithMaxResponseBytes(10))
+	require.NoError(t, err)
+
+	m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "listItems", Arguments: `{"Authorization": "Bearer stolen"}`})
+	require.NoError(t, err)
+
+	// The model can't replace the configured credentials.
+	assert.Equal(t, []string{"Bearer secret"}, got.Values("Authorization"))
+
+	content, _ := m.Content(context.Background())
+	assert.Equal(t, "HTTP 200 OK\n\nxxxxxxxxxx\n\n[response truncated after 10 bytes]", content)
+}
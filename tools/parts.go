@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rhettg/agent"
+)
+
+// Result is the output of a tool made of several parts, such as a caption
+// alongside a rendered chart.
+type Result struct {
+	Parts []Part
+}
+
+// Part is one piece of a Result. Only one of the fields is set.
+type Part struct {
+	Text string
+
+	// JSON is encoded and sent to the model as text.
+	JSON any
+
+	Image *agent.Image
+}
+
+// Text returns a text part.
+func Text(s string) Part {
+	return Part{Text: s}
+}
+
+// JSON returns a part holding a value to be encoded as JSON.
+func JSON(v any) Part {
+	return Part{JSON: v}
+}
+
+// Image returns an image part. The name's extension indicates the format,
+// as with agent.Message.AddImage.
+func Image(name string, data []byte) Part {
+	return Part{Image: &agent.Image{Name: name, Data: data}}
+}
+
+// NewResult returns a result made of the given parts.
+func NewResult(parts ...Part) *Result {
+	return &Result{Parts: parts}
+}
+
+// ResultTool is a tool that returns a Result rather than a string.
+type ResultTool func(ctx context.Context, arguments string) (*Result, error)
+
+// AddResultTool registers a tool that returns a Result. Its text and JSON
+// parts become the content of the tool message and its images are attached
+// to the message.
+func (f *Tools) AddResultTool(name, description string, parameters any, fn ResultTool, opts ...ToolOption) error {
+	def := agent.ToolDef{
+		Name:        name,
+		Description: description,
+		Parameters:  parameters,
+	}
+
+	f.defsMu.Lock()
+	defer f.defsMu.Unlock()
+
+	if _, ok := f.tools[name]; ok {
+		return fmt.Errorf("%w: %s", ErrToolExists, name)
+	}
+
+	f.defs = append(f.defs, def)
+	f.tools[name] = newTool(fn, parameters, opts...)
+
+	return nil
+}
+
+// textTool adapts a tool returning a string.
+func textTool(fn agent.Tool) ResultTool {
+	return func(ctx context.Context, arguments string) (*Result, error) {
+		resp, err := fn(ctx, arguments)
+		if err != nil {
+			return nil, err
+		}
+		return NewResult(Text(resp)), nil
+	}
+}
+
+// render joins the text and JSON parts of the result and collects its
+// images.
+func (r *Result) render() (string, []agent.Image, error) {
+	if r == nil {
+		return "", nil, nil
+	}
+
+	var texts []string
+	var images []agent.Image
+	for _, p := range r.Parts {
+		switch {
+		case p.Image != nil:
+			images = append(images, *p.Image)
+		case p.JSON != nil:
+			data, err := json.Marshal(p.JSON)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to encode result: %w", err)
+			}
+			texts = append(texts, string(data))
+		default:
+			texts = append(texts, p.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n"), images, nil
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultTool(t *testing.T) {
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 40}))
+
+	chart := func(ctx context.Context, arguments string) (*Result, error) {
+		return NewResult(
+			Text("Sales by month:"),
+			Image("chart.png", []byte("png data")),
+			JSON(map[string]int{"jan": 10, "feb": 20}),
+		), nil
+	}
+	require.NoError(t, ts.AddResultTool("chart", "Render a chart", EmptyParameters, chart))
+
+	m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "chart"})
+	require.NoError(t, err)
+
+	content, err := m.Content(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Sales by month:\n{\"feb\":20,\"jan\":10}", content)
+	assert.Equal(t, []agent.Image{{Name: "chart.png", Data: []byte("png data")}}, m.Images())
+
+	// Limits apply to the text, leaving images alone.
+	big := func(ctx context.Context, arguments string) (*Result, error) {
+		return NewResult(Text(string(make([]byte, 100))), Image("a.png", []byte("a"))), nil
+	}
+	require.NoError(t, ts.AddResultTool("big", "Big output", EmptyParameters, big))
+
+	m, err = ts.Call(context.Background(), agent.ToolCall{ID: "call_2", Name: "big"})
+	require.NoError(t, err)
+	assert.True(t, m.HasTag(TruncatedTag))
+	assert.Len(t, m.Images(), 1)
+}
+
+func TestResultToolEncodeError(t *testing.T) {
+	ts := New(WithErrorHandler(SurfaceErrors))
+
+	bad := func(ctx context.Context, arguments string) (*Result, error) {
+		return NewResult(JSON(make(chan int))), nil
+	}
+	require.NoError(t, ts.AddResultTool("bad", "Bad output", EmptyParameters, bad))
+
+	m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "bad"})
+	require.NoError(t, err)
+	assert.True(t, m.HasTag(ErrorTag))
+}
+
+func TestAddFuncResult(t *testing.T) {
+	ts := New()
+
+	type screenshotArgs struct {
+		URL string `json:"url"`
+	}
+
+	screenshot := func(ctx context.Context, args screenshotArgs) (*Result, error) {
+		return NewResult(Text("Screenshot of "+args.URL), Image("page.jpg", []byte("jpeg"))), nil
+	}
+	require.NoError(t, AddFunc(ts, "screenshot", "Take a screenshot", screenshot))
+
+	m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "screenshot", Arguments: `{"url": "https://example.com"}`})
+	require.NoError(t, err)
+
+	content, err := m.Content(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Screenshot of https://example.com", content)
+	assert.Len(t, m.Images(), 1)
+}
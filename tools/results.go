@@ -0,0 +1,335 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rhettg/agent"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// ReadResultTool is the name of the tool registered by ResultStore for
+// reading stored tool output.
+const ReadResultTool = "read_tool_result"
+
+// TruncatedTag is set on tool messages whose content was reduced to fit a
+// ResultLimit.
+const TruncatedTag = "tools:truncated"
+
+// ResultMode determines what happens to tool output exceeding a ResultLimit.
+type ResultMode int
+
+const (
+	// ResultTruncate cuts the output down to size and adds a marker.
+	ResultTruncate ResultMode = iota
+
+	// ResultStore truncates the output but keeps the full result, which the
+	// model can page through with the read_tool_result tool.
+	ResultStore
+
+	// ResultSummarize replaces the output with a summary generated by the
+	// Summarizer.
+	ResultSummarize
+)
+
+// ResultLimit restricts the size of tool results added to the conversation.
+type ResultLimit struct {
+	// MaxBytes is the maximum size of the result content. Zero means no limit.
+	MaxBytes int
+
+	// MaxTokens is the maximum size of the result message as measured by
+	// agent.EstimateTokens. It requires a Codec. Zero means no limit.
+	MaxTokens int
+	Codec     tokenizer.Codec
+
+	Mode ResultMode
+
+	// MaxStored is the number of full results kept in ResultStore mode. The
+	// oldest are dropped first. Zero means DefaultMaxStored.
+	MaxStored int
+
+	// Summarizer is used in ResultSummarize mode.
+	Summarizer agent.CompletionFunc
+}
+
+// DefaultMaxStored is the number of results kept by ResultStore when
+// MaxStored isn't set.
+const DefaultMaxStored = 100
+
+// WithResultLimit applies a size limit to tool results, replacing any earlier
+// limit. In ResultStore mode this also registers the read_tool_result tool,
+// which isn't sent to the Approver. If another tool already has that name,
+// results exceeding the limit fail with the error instead.
+func WithResultLimit(l ResultLimit) Option {
+	return func(f *Tools) {
+		f.resultLimit = &l
+		f.storeErr = nil
+
+		if l.Mode != ResultStore {
+			if f.stored != nil {
+				f.Remove(ReadResultTool)
+				f.stored = nil
+			}
+			return
+		}
+
+		limit := l.MaxStored
+		if limit <= 0 {
+			limit = DefaultMaxStored
+		}
+
+		if f.stored != nil {
+			f.stored.setMax(limit)
+			return
+		}
+
+		// The schema of readResultArgs is static, so this can only fail if
+		// the name is taken by another tool.
+		err := AddFunc(f, ReadResultTool, "Read part of a tool result that was too large to show in full", f.readResult, internalTool())
+		if err != nil {
+			f.storeErr = fmt.Errorf("cannot store tool results: %w", err)
+			return
+		}
+
+		f.stored = &resultStore{results: make(map[string]string), max: limit}
+	}
+}
+
+// resultStore holds the full output of truncated tool results, dropping the
+// oldest once it holds max of them.
+type resultStore struct {
+	mu      sync.Mutex
+	results map[string]string
+	order   []string
+	max     int
+}
+
+// newResultID returns a key for a stored result. Call IDs are only unique
+// within a conversation, so a random suffix keeps conversations sharing a
+// Tools from overwriting each other's results.
+func newResultID(toolCallID string) string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return "result_" + toolCallID + "_" + hex.EncodeToString(b[:])
+}
+
+func (s *resultStore) put(id, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.results[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.results[id] = content
+	s.evict()
+}
+
+func (s *resultStore) setMax(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.max = n
+	s.evict()
+}
+
+func (s *resultStore) evict() {
+	for len(s.order) > s.max {
+		delete(s.results, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *resultStore) get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.results[id]
+	return content, ok
+}
+
+type readResultArgs struct {
+	ID     string `json:"id" description:"id of the stored result"`
+	Offset int    `json:"offset,omitempty" description:"byte offset to start reading from" minimum:"0"`
+}
+
+func (f *Tools) readResult(ctx context.Context, args readResultArgs) (string, error) {
+	// The store is gone if the limit was changed since, but a set the tool
+	// was copied to can still call it.
+	var content string
+	ok := false
+	if f.stored != nil {
+		content, ok = f.stored.get(args.ID)
+	}
+
+	if !ok {
+		return fmt.Sprintf("no stored result with id %s", args.ID), nil
+	}
+
+	if args.Offset >= len(content) {
+		return fmt.Sprintf("offset %d is past the end of the result (%d bytes)", args.Offset, len(content)), nil
+	}
+
+	offset := args.Offset
+	for offset > 0 && !utf8.RuneStart(content[offset]) {
+		offset--
+	}
+
+	return f.resultLimit.fit(ctx, content[offset:], func(shown int) string {
+		return fmt.Sprintf("\n\n[showing bytes %d to %d of %d. Call %s with id %q and offset %d to read more]",
+			offset, offset+shown, len(content), ReadResultTool, args.ID, offset+shown)
+	})
+}
+
+func measure(ctx context.Context, codec tokenizer.Codec, content string) (int, error) {
+	return agent.EstimateTokens(ctx, codec, agent.NewContentMessage(agent.RoleTool, content))
+}
+
+func (l *ResultLimit) exceeded(ctx context.Context, content string) (bool, error) {
+	if l.MaxBytes > 0 && len(content) > l.MaxBytes {
+		return true, nil
+	}
+
+	if l.MaxTokens > 0 && l.Codec != nil {
+		n, err := measure(ctx, l.Codec, content)
+		if err != nil {
+			return false, err
+		}
+		return n > l.MaxTokens, nil
+	}
+
+	return false, nil
+}
+
+// fit truncates content so that it, along with the marker describing the
+// truncation, is within the limit. Content that already fits is returned
+// unchanged.
+func (l *ResultLimit) fit(ctx context.Context, content string, marker func(shown int) string) (string, error) {
+	exceeded, err := l.exceeded(ctx, content)
+	if err != nil || !exceeded {
+		return content, err
+	}
+
+	// The marker is largest when all of the content is shown. When even
+	// the marker is over the byte limit it is left out.
+	reserve := marker(len(content))
+	if l.MaxBytes > 0 && len(reserve) > l.MaxBytes {
+		reserve = ""
+		marker = func(int) string { return "" }
+	}
+
+	n := len(content)
+	if l.MaxBytes > 0 {
+		n = min(max(l.MaxBytes-len(reserve), 0), len(content))
+		for n > 0 && n < len(content) && !utf8.RuneStart(content[n]) {
+			n--
+		}
+	}
+	shown := content[:n]
+
+	if l.MaxTokens > 0 && l.Codec != nil {
+		total, err := measure(ctx, l.Codec, shown+reserve)
+		if err != nil {
+			return "", err
+		}
+
+		if total > l.MaxTokens {
+			ids, _, err := l.Codec.Encode(shown)
+			if err != nil {
+				return "", err
+			}
+
+			keep := len(ids) - (total - l.MaxTokens)
+			if keep < 0 {
+				keep = 0
+			}
+
+			shown, err = l.Codec.Decode(ids[:keep])
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return shown + marker(len(shown)), nil
+}
+
+func (l *ResultLimit) summarize(ctx context.Context, toolName, content string) (string, error) {
+	if l.Summarizer == nil {
+		return "", fmt.Errorf("no summarizer configured")
+	}
+
+	msgs := []*agent.Message{
+		agent.NewContentMessage(agent.RoleSystem, fmt.Sprintf(
+			"Summarize the following output of the %s tool. Keep any details that are likely to be important for completing the task.",
+			toolName)),
+		agent.NewContentMessage(agent.RoleUser, content),
+	}
+
+	m, err := l.Summarizer(ctx, msgs, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if m == nil {
+		return "", fmt.Errorf("summarizer returned no message")
+	}
+
+	return m.Content(ctx)
+}
+
+// limitResult applies the result limit to the output of a tool call. It
+// reports whether the output was changed.
+func (f *Tools) limitResult(ctx context.Context, toolCall *agent.ToolCall, content string) (string, bool, error) {
+	l := f.resultLimit
+
+	// Stored results are paged by the read tool itself.
+	if l == nil || toolCall.Name == ReadResultTool {
+		return content, false, nil
+	}
+
+	exceeded, err := l.exceeded(ctx, content)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to measure tool result: %w", err)
+	}
+
+	if !exceeded {
+		return content, false, nil
+	}
+
+	switch l.Mode {
+	case ResultSummarize:
+		summary, err := l.summarize(ctx, toolCall.Name, content)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to summarize tool result: %w", err)
+		}
+		return fmt.Sprintf("[summary of %d bytes of output]\n%s", len(content), summary), true, nil
+	case ResultStore:
+		if f.stored == nil {
+			return "", false, f.storeErr
+		}
+
+		id := newResultID(toolCall.ID)
+
+		f.stored.put(id, content)
+
+		truncated, err := l.fit(ctx, content, func(shown int) string {
+			return fmt.Sprintf("\n\n[truncated: showing %d of %d bytes. Call %s with id %q and offset %d to read more]",
+				shown, len(content), ReadResultTool, id, shown)
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to truncate tool result: %w", err)
+		}
+		return truncated, true, nil
+	default:
+		truncated, err := l.fit(ctx, content, func(shown int) string {
+			return fmt.Sprintf("\n\n[truncated: showing %d of %d bytes]", shown, len(content))
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to truncate tool result: %w", err)
+		}
+		return truncated, true, nil
+	}
+}
@@ -0,0 +1,217 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+func bigResult(ctx context.Context, args string) (string, error) {
+	return strings.Repeat("abcdefghij", 100), nil
+}
+
+func TestResultLimitTruncate(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 100}))
+	ts.Add("big", "Big output", EmptyParameters, bigResult)
+	ts.Add("small", "Small output", EmptyParameters, echo)
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "big", Arguments: "{}"})
+	require.NoError(t, err)
+	assert.True(t, msg.HasTag(TruncatedTag))
+
+	content, _ := msg.Content(ctx)
+	assert.LessOrEqual(t, len(content), 100)
+	assert.True(t, strings.HasPrefix(content, "abcdefghij"))
+	assert.Regexp(t, `\[truncated: showing \d+ of 1000 bytes\]$`, content)
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: "small", Arguments: "{}"})
+	require.NoError(t, err)
+	assert.False(t, msg.HasTag(TruncatedTag))
+}
+
+func TestResultLimitTokens(t *testing.T) {
+	ctx := context.Background()
+
+	codec, err := tokenizer.Get(tokenizer.Cl100kBase)
+	require.NoError(t, err)
+
+	ts := New(WithResultLimit(ResultLimit{MaxTokens: 50, Codec: codec}))
+	ts.Add("big", "Big output", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		return strings.Repeat("hello world ", 200), nil
+	})
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "big", Arguments: "{}"})
+	require.NoError(t, err)
+	assert.True(t, msg.HasTag(TruncatedTag))
+
+	n, err := agent.EstimateTokens(ctx, codec, msg)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, n, 50)
+}
+
+func TestResultLimitStore(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 300, Mode: ResultStore}))
+	ts.Add("big", "Big output", EmptyParameters, bigResult)
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "big", Arguments: "{}"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	match := regexp.MustCompile(`Call read_tool_result with id "(result_1_\w+)"`).FindStringSubmatch(content)
+	require.NotNil(t, match, content)
+	id := match[1]
+
+	shown := strings.Index(content, "\n\n[truncated")
+	full := content[:shown]
+
+	// Page through the rest of the stored result.
+	for len(full) < 1000 {
+		args := fmt.Sprintf(`{"id": %q, "offset": %d}`, id, len(full))
+		msg, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: ReadResultTool, Arguments: args})
+		require.NoError(t, err)
+
+		content, _ = msg.Content(ctx)
+		assert.LessOrEqual(t, len(content), 300)
+		if i := strings.Index(content, "\n\n[showing"); i >= 0 {
+			content = content[:i]
+		}
+		require.NotEmpty(t, content)
+		full += content
+	}
+
+	r, _ := bigResult(ctx, "")
+	assert.Equal(t, r, full)
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "3", Name: ReadResultTool, Arguments: `{"id": "missing"}`})
+	require.NoError(t, err)
+	content, _ = msg.Content(ctx)
+	assert.Equal(t, "no stored result with id missing", content)
+}
+
+func TestResultLimitBytesAndTokens(t *testing.T) {
+	ctx := context.Background()
+
+	codec, err := tokenizer.Get(tokenizer.Cl100kBase)
+	require.NoError(t, err)
+
+	// The content is under the byte limit but over the token limit.
+	l := ResultLimit{MaxBytes: 10000, MaxTokens: 40, Codec: codec}
+	content := strings.Repeat("hello world ", 50)
+
+	got, err := l.fit(ctx, content, func(shown int) string {
+		return fmt.Sprintf("\n\n[truncated: showing %d of %d bytes]", shown, len(content))
+	})
+	require.NoError(t, err)
+	assert.Contains(t, got, "[truncated")
+
+	n, err := measure(ctx, codec, got)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, n, 40)
+
+	// A marker longer than the byte limit is left out.
+	l = ResultLimit{MaxBytes: 10, MaxTokens: 20, Codec: codec}
+	got, err = l.fit(ctx, content, func(shown int) string {
+		return fmt.Sprintf("\n\n[truncated: showing %d of %d bytes]", shown, len(content))
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello worl", got)
+}
+
+func TestResultLimitStoreEvicts(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 300, Mode: ResultStore, MaxStored: 2}))
+	ts.Add("big", "Big output", EmptyParameters, bigResult)
+
+	for _, id := range []string{"1", "2", "3", "1"} {
+		_, err := ts.call(ctx, &agent.ToolCall{ID: id, Name: "big", Arguments: "{}"})
+		require.NoError(t, err)
+	}
+
+	// The same call ID twice is stored under different keys, and only the
+	// newest results are kept.
+	assert.Len(t, ts.stored.results, 2)
+	assert.Len(t, ts.stored.order, 2)
+	assert.True(t, strings.HasPrefix(ts.stored.order[0], "result_3_"))
+	assert.True(t, strings.HasPrefix(ts.stored.order[1], "result_1_"))
+}
+
+func TestWithResultLimitTwice(t *testing.T) {
+	ts := New(
+		WithResultLimit(ResultLimit{MaxBytes: 300, Mode: ResultStore}),
+		WithResultLimit(ResultLimit{MaxBytes: 100, Mode: ResultStore}),
+	)
+	assert.Equal(t, 100, ts.resultLimit.MaxBytes)
+	assert.True(t, ts.Has(ReadResultTool))
+
+	WithResultLimit(ResultLimit{MaxBytes: 100})(ts)
+	assert.False(t, ts.Has(ReadResultTool))
+	assert.Nil(t, ts.stored)
+}
+
+func TestResultLimitStoreNameTaken(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+	ts.Add(ReadResultTool, "Something else", EmptyParameters, bigResult)
+	ts.Add("big", "Big output", EmptyParameters, bigResult)
+
+	WithResultLimit(ResultLimit{MaxBytes: 300, Mode: ResultStore})(ts)
+	assert.Nil(t, ts.stored)
+	assert.True(t, ts.Has(ReadResultTool))
+
+	_, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "big", Arguments: "{}"})
+	assert.ErrorIs(t, err, ErrToolExists)
+
+	// Leaving store mode doesn't remove the other tool.
+	WithResultLimit(ResultLimit{MaxBytes: 300})(ts)
+	assert.True(t, ts.Has(ReadResultTool))
+	_, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: "big", Arguments: "{}"})
+	assert.NoError(t, err)
+}
+
+func TestResultLimitStoreApprover(t *testing.T) {
+	ctx := context.Background()
+	ts := New(
+		WithResultLimit(ResultLimit{MaxBytes: 300, Mode: ResultStore}),
+		WithApprover(&RuleApprover{Allow: []string{"big"}}),
+	)
+	ts.Add("big", "Big output", EmptyParameters, bigResult)
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "big", Arguments: "{}"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	id := regexp.MustCompile(`id "(result_1_\w+)"`).FindStringSubmatch(content)[1]
+
+	// Paging isn't held up by the approver's default deny.
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: ReadResultTool, Arguments: fmt.Sprintf(`{"id": %q, "offset": 900}`, id)})
+	require.NoError(t, err)
+	assert.False(t, msg.HasTag(DeniedTag))
+	content, _ = msg.Content(ctx)
+	assert.Equal(t, strings.Repeat("abcdefghij", 10), content)
+}
+
+func TestResultLimitSummarize(t *testing.T) {
+	ctx := context.Background()
+
+	summarizer := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		require.Len(t, msgs, 2)
+		content, _ := msgs[1].Content(ctx)
+		return agent.NewContentMessage(agent.RoleAssistant, fmt.Sprintf("%d letters", len(content))), nil
+	}
+
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 100, Mode: ResultSummarize, Summarizer: summarizer}))
+	ts.Add("big", "Big output", EmptyParameters, bigResult)
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "big", Arguments: "{}"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, "[summary of 1000 bytes of output]\n1000 letters", content)
+	assert.True(t, msg.HasTag(TruncatedTag))
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema generates a JSON Schema describing the arguments struct v.
+//
+// Field names come from the `json` tag. Fields are required unless they are
+// pointers or tagged `omitempty`. Additional constraints are read from these
+// tags:
+//
+//	description:"what the field is for"
+//	enum:"red,green,blue"
+//	minimum:"0"
+//	maximum:"100"
+//
+// Types that encode themselves follow their JSON form: text marshalers such
+// as netip.Addr are strings, and json.Marshaler types accept any value.
+func Schema(v any) (map[string]any, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("cannot generate schema for nil")
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("tool arguments must be a struct, got %s", t)
+	}
+
+	return schemaFor(t, make(map[reflect.Type]bool))
+}
+
+var (
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// implements reports whether t or a pointer to it implements iface, as
+// encoding/json checks for addressable values.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface))
+}
+
+// schemaFor describes t. Structs being described are tracked in inProgress
+// so that recursive types are reported rather than expanded forever.
+func schemaFor(t reflect.Type, inProgress map[reflect.Type]bool) (map[string]any, error) {
+	switch {
+	case t == rawMessageType:
+		return map[string]any{}, nil
+	case t == timeType:
+		// encoding/json writes times as RFC 3339 strings.
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case implements(t, jsonMarshalerType):
+		// The JSON form is up to the type, so anything is allowed.
+		return map[string]any{}, nil
+	case implements(t, textMarshalerType):
+		// encoding/json writes text marshalers, such as netip.Addr, as strings.
+		return map[string]any{"type": "string"}, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		// encoding/json writes byte slices as base64 strings.
+		return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), inProgress)
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaFor(t.Elem(), inProgress)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaFor(t.Elem(), inProgress)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return structSchema(t, inProgress)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func structSchema(t reflect.Type, inProgress map[reflect.Type]bool) (map[string]any, error) {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+
+	if err := addFields(t, properties, &required, inProgress); err != nil {
+		return nil, err
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s, nil
+}
+
+func addFields(t reflect.Type, properties map[string]any, required *[]string, inProgress map[reflect.Type]bool) error {
+	if inProgress[t] {
+		return fmt.Errorf("recursive type %s is not supported", t)
+	}
+	inProgress[t] = true
+	defer delete(inProgress, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, omitEmpty, skip := jsonName(f)
+		if skip {
+			continue
+		}
+
+		// Embedded structs without a name of their own are flattened, matching
+		// encoding/json.
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addFields(ft, properties, required, inProgress); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		ps, err := schemaFor(f.Type, inProgress)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+
+		if d := f.Tag.Get("description"); d != "" {
+			ps["description"] = d
+		}
+
+		if e := f.Tag.Get("enum"); e != "" {
+			values, err := enumValues(f.Type, e)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			ps["enum"] = values
+		}
+
+		for _, key := range []string{"minimum", "maximum"} {
+			if v := f.Tag.Get(key); v != "" {
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return fmt.Errorf("field %s: invalid %s %q", f.Name, key, v)
+				}
+				ps[key] = n
+			}
+		}
+
+		properties[name] = ps
+
+		if !omitEmpty && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+
+	return nil
+}
+
+func jsonName(f reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+
+	for _, o := range strings.Split(opts, ",") {
+		if o == "omitempty" || o == "omitzero" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty, false
+}
+
+func enumValues(t reflect.Type, tag string) ([]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	values := make([]any, 0)
+	for _, v := range strings.Split(tag, ",") {
+		switch t.Kind() {
+		case reflect.String:
+			values = append(values, v)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum value %q", v)
+			}
+			values = append(values, n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum value %q", v)
+			}
+			values = append(values, n)
+		default:
+			return nil, fmt.Errorf("enum not supported for %s", t)
+		}
+	}
+
+	return values, nil
+}
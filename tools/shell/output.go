@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"fmt"
+	"sync"
+)
+
+// outputBuffer keeps the beginning and end of output that is longer than its
+// limit, since build errors tend to appear first and test summaries last.
+type outputBuffer struct {
+	mu    sync.Mutex
+	limit int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func newOutputBuffer(limit int) *outputBuffer {
+	return &outputBuffer{limit: limit}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	b.total += n
+
+	headLimit := b.limit / 2
+	if h := min(headLimit-len(b.head), len(p)); h > 0 {
+		b.head = append(b.head, p[:h]...)
+		p = p[h:]
+	}
+
+	tailLimit := b.limit - headLimit
+	b.tail = append(b.tail, p...)
+	if len(b.tail) > tailLimit {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-tailLimit:]...)
+	}
+
+	return n, nil
+}
+
+func (b *outputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	omitted := b.total - len(b.head) - len(b.tail)
+	if omitted == 0 {
+		return string(b.head) + string(b.tail)
+	}
+
+	return fmt.Sprintf("%s\n[%d bytes of output omitted]\n%s", b.head, omitted, b.tail)
+}
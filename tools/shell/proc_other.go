@@ -0,0 +1,9 @@
+//go:build !unix
+
+package shell
+
+import "os/exec"
+
+// killProcessGroup does nothing where process groups aren't available; only
+// the command itself is killed when it is canceled.
+func killProcessGroup(cmd *exec.Cmd) {}
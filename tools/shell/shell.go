@@ -0,0 +1,255 @@
+// Package shell provides a tool for running commands in a directory.
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rhettg/agent/tools"
+)
+
+var (
+	// ErrNotAllowed is returned for commands rejected by the allow or deny
+	// lists.
+	ErrNotAllowed = errors.New("command not allowed")
+
+	// ErrEscape is returned for working directories outside the root.
+	ErrEscape = errors.New("directory escapes root")
+)
+
+const (
+	defaultTimeout   = 2 * time.Minute
+	defaultMaxOutput = 32 * 1024
+)
+
+// defaultEnv lists the variables passed through from the environment of the
+// current process.
+var defaultEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TMPDIR"}
+
+// Runner runs commands confined to a root directory.
+type Runner struct {
+	root      string
+	allow     []string
+	deny      []string
+	env       []string
+	setEnv    []string
+	timeout   time.Duration
+	maxOutput int
+}
+
+type Option func(r *Runner)
+
+// WithAllow permits only the named commands. Patterns use path.Match syntax
+// and are matched against the command as given, so "go" allows go from PATH
+// but not ./go.
+func WithAllow(patterns ...string) Option {
+	return func(r *Runner) {
+		r.allow = append(r.allow, patterns...)
+	}
+}
+
+// WithDeny refuses the named commands, even if they are allowed. Patterns are
+// matched against the command as given and its base name, so "rm" also
+// refuses /bin/rm.
+func WithDeny(patterns ...string) Option {
+	return func(r *Runner) {
+		r.deny = append(r.deny, patterns...)
+	}
+}
+
+// WithEnv passes the named variables through from the current environment,
+// in addition to PATH, HOME, USER, LANG, LC_ALL and TMPDIR. Other variables,
+// such as API keys, are not visible to commands.
+func WithEnv(keys ...string) Option {
+	return func(r *Runner) {
+		r.env = append(r.env, keys...)
+	}
+}
+
+// WithSetEnv sets a variable for every command.
+func WithSetEnv(key, value string) Option {
+	return func(r *Runner) {
+		r.setEnv = append(r.setEnv, key+"="+value)
+	}
+}
+
+// WithTimeout sets how long a command may run before it is killed.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.timeout = d
+	}
+}
+
+// WithMaxOutput limits how many bytes of output are returned. When output is
+// longer, the beginning and end are kept.
+func WithMaxOutput(n int) Option {
+	return func(r *Runner) {
+		r.maxOutput = n
+	}
+}
+
+// New returns a runner whose commands run in root or a directory beneath it.
+func New(root string, opts ...Option) (*Runner, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid root: %s is not a directory", root)
+	}
+
+	r := &Runner{
+		root:      abs,
+		env:       append([]string{}, defaultEnv...),
+		timeout:   defaultTimeout,
+		maxOutput: defaultMaxOutput,
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r, nil
+}
+
+// Tools returns a tool set containing run_command.
+func (r *Runner) Tools() (*tools.Tools, error) {
+	ts := tools.New()
+
+	desc := fmt.Sprintf("Run a command and return its exit code and combined output. The command is run directly, not through a shell, so pipes, redirection and globs are not available. Commands are killed after %s.", r.timeout)
+	if len(r.allow) > 0 {
+		desc += " Allowed commands: " + strings.Join(r.allow, ", ") + "."
+	}
+
+	if err := tools.AddFunc(ts, "run_command", desc, r.runCommand); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// allowed checks the command against the deny list and then the allow list.
+func (r *Runner) allowed(command string) bool {
+	for _, p := range r.deny {
+		if ok, _ := path.Match(p, command); ok {
+			return false
+		}
+		if ok, _ := path.Match(p, path.Base(command)); ok {
+			return false
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return true
+	}
+
+	for _, p := range r.allow {
+		if ok, _ := path.Match(p, command); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dir resolves the working directory, which must be inside the root after
+// following symbolic links.
+func (r *Runner) dir(name string) (string, error) {
+	p := filepath.FromSlash(name)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(r.root, p)
+	}
+
+	p, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", fmt.Errorf("invalid directory %s: %w", name, err)
+	}
+
+	rel, err := filepath.Rel(r.root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrEscape, name)
+	}
+
+	return p, nil
+}
+
+// environ builds the environment for commands from scratch.
+func (r *Runner) environ() []string {
+	var env []string
+	for _, k := range r.env {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return append(env, r.setEnv...)
+}
+
+type runCommandArgs struct {
+	Command string   `json:"command" description:"The program to run, e.g. go."`
+	Args    []string `json:"args,omitempty" description:"Arguments passed to the program, e.g. [\"test\", \"./...\"]."`
+	Dir     string   `json:"dir,omitempty" description:"Working directory relative to the root. Defaults to the root."`
+}
+
+func (r *Runner) runCommand(ctx context.Context, args runCommandArgs) (string, error) {
+	if args.Command == "" {
+		return "", fmt.Errorf("command is required")
+	}
+
+	if !r.allowed(args.Command) {
+		return "", fmt.Errorf("%w: %s", ErrNotAllowed, args.Command)
+	}
+
+	dir, err := r.dir(args.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cctx, args.Command, args.Args...)
+	cmd.Dir = dir
+	cmd.Env = r.environ()
+
+	out := newOutputBuffer(r.maxOutput)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	// Kill anything the command started too, and don't wait long for
+	// orphaned processes holding the output open.
+	killProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	// The caller's deadline or cancellation fails the call; only our own
+	// timeout is reported to the model.
+	switch {
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case errors.Is(cctx.Err(), context.DeadlineExceeded):
+		return fmt.Sprintf("command timed out after %s\n%s", r.timeout, out), nil
+	case cmd.ProcessState == nil:
+		return "", fmt.Errorf("failed to run %s: %w", args.Command, err)
+	}
+
+	return fmt.Sprintf("exit code: %d (%s)\n%s", cmd.ProcessState.ExitCode(), elapsed, out), nil
+}
@@ -0,0 +1,150 @@
+//go:build unix
+
+package shell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+
+	r, err := New(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	out, err := r.runCommand(ctx, runCommandArgs{Command: "echo", Args: []string{"hello", "$HOME"}})
+	require.NoError(t, err)
+	assert.Regexp(t, `^exit code: 0 \(.*\)\nhello \$HOME\n$`, out)
+
+	out, err = r.runCommand(ctx, runCommandArgs{Command: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}})
+	require.NoError(t, err)
+	assert.Regexp(t, `^exit code: 3 \(.*\)\noops\n$`, out)
+
+	out, err = r.runCommand(ctx, runCommandArgs{Command: "pwd", Dir: "sub"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(out, "/sub\n"), out)
+
+	_, err = r.runCommand(ctx, runCommandArgs{Command: "no-such-command"})
+	assert.Error(t, err)
+}
+
+func TestRunCommandDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Symlink(os.TempDir(), filepath.Join(dir, "link")))
+
+	r, err := New(dir)
+	require.NoError(t, err)
+
+	for _, d := range []string{"..", "/", "link"} {
+		_, err := r.runCommand(context.Background(), runCommandArgs{Command: "pwd", Dir: d})
+		assert.ErrorIs(t, err, ErrEscape, d)
+	}
+
+	_, err = r.runCommand(context.Background(), runCommandArgs{Command: "pwd", Dir: "missing"})
+	assert.Error(t, err)
+}
+
+func TestAllowed(t *testing.T) {
+	r, err := New(t.TempDir(), WithAllow("go", "git", "make*"), WithDeny("git", "rm"))
+	require.NoError(t, err)
+
+	assert.True(t, r.allowed("go"))
+	assert.True(t, r.allowed("makefile-lint"))
+	assert.False(t, r.allowed("git"))
+	assert.False(t, r.allowed("./go"))
+	assert.False(t, r.allowed("/usr/local/go/bin/go"))
+	assert.False(t, r.allowed("sh"))
+
+	r, err = New(t.TempDir(), WithDeny("rm"))
+	require.NoError(t, err)
+
+	assert.True(t, r.allowed("ls"))
+	assert.False(t, r.allowed("rm"))
+	assert.False(t, r.allowed("/bin/rm"))
+
+	_, err = r.runCommand(context.Background(), runCommandArgs{Command: "rm", Args: []string{"-rf", "."}})
+	assert.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("SECRET_API_KEY", "secret")
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	r, err := New(t.TempDir(), WithEnv("GOFLAGS"), WithSetEnv("CI", "true"))
+	require.NoError(t, err)
+
+	out, err := r.runCommand(context.Background(), runCommandArgs{Command: "env"})
+	require.NoError(t, err)
+	assert.NotContains(t, out, "SECRET_API_KEY")
+	assert.Contains(t, out, "GOFLAGS=-mod=mod\n")
+	assert.Contains(t, out, "CI=true\n")
+	assert.Contains(t, out, "PATH=")
+}
+
+func TestTimeout(t *testing.T) {
+	r, err := New(t.TempDir(), WithTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	// The background sleep holds the output open; it must be killed too.
+	start := time.Now()
+	out, err := r.runCommand(context.Background(), runCommandArgs{Command: "sh", Args: []string{"-c", "echo started; sleep 10 & sleep 10"}})
+	require.NoError(t, err)
+	assert.Equal(t, "command timed out after 100ms\nstarted\n", out)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.runCommand(ctx, runCommandArgs{Command: "true"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The caller's deadline isn't reported as the command's timeout.
+	r, err = New(t.TempDir(), WithTimeout(time.Minute))
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = r.runCommand(ctx, runCommandArgs{Command: "sleep", Args: []string{"10"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOutputLimit(t *testing.T) {
+	b := newOutputBuffer(10)
+	for _, s := range []string{"abc", "defgh", "ijklmnop", "qrst"} {
+		n, err := b.Write([]byte(s))
+		require.NoError(t, err)
+		assert.Equal(t, len(s), n)
+	}
+	assert.Equal(t, "abcde\n[10 bytes of output omitted]\npqrst", b.String())
+
+	b = newOutputBuffer(10)
+	_, _ = b.Write([]byte("short"))
+	assert.Equal(t, "short", b.String())
+}
+
+func TestTools(t *testing.T) {
+	r, err := New(t.TempDir(), WithAllow("echo"))
+	require.NoError(t, err)
+
+	ts, err := r.Tools()
+	require.NoError(t, err)
+
+	defs := ts.Defs()
+	require.Len(t, defs, 1)
+	assert.Contains(t, defs[0].Description, "Allowed commands: echo.")
+
+	m, err := ts.Call(context.Background(), agent.ToolCall{ID: "call_1", Name: "run_command", Arguments: `{"command": "echo", "args": ["hi"]}`})
+	require.NoError(t, err)
+	content, err := m.Content(context.Background())
+	require.NoError(t, err)
+	assert.Regexp(t, `^exit code: 0 \(.*\)\nhi\n$`, content)
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/rhettg/agent"
+)
+
+// toolsSnapshot holds the unreturned parallel results by assistant message
+// ID and tool call ID. Failed calls aren't saved, so they run again.
+type toolsSnapshot struct {
+	Results map[string]map[string]*agent.Message `json:"results,omitempty"`
+	Stored  []storedResult                       `json:"stored,omitempty"`
+}
+
+// storedResult is one entry of the ResultStore, saved oldest first.
+type storedResult struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// Snapshot saves the results of parallel calls that haven't been returned
+// yet and, with ResultStore, the stored tool output. A Tools shared between
+// conversations saves the results of all of them; they're kept apart by
+// assistant message and result ID, so restoring them is harmless.
+func (f *Tools) Snapshot(ctx context.Context) (json.RawMessage, error) {
+	var ts toolsSnapshot
+
+	f.mu.Lock()
+	for msgID, b := range f.results {
+		for callID, r := range b {
+			if r.err != nil {
+				continue
+			}
+			if ts.Results == nil {
+				ts.Results = make(map[string]map[string]*agent.Message)
+			}
+			if ts.Results[msgID] == nil {
+				ts.Results[msgID] = make(map[string]*agent.Message)
+			}
+			ts.Results[msgID][callID] = r.msg
+		}
+	}
+	f.mu.Unlock()
+
+	if f.stored != nil {
+		f.stored.mu.Lock()
+		for _, id := range f.stored.order {
+			ts.Stored = append(ts.Stored, storedResult{ID: id, Content: f.stored.results[id]})
+		}
+		f.stored.mu.Unlock()
+	}
+
+	return json.Marshal(ts)
+}
+
+// Restore adds the saved pending and stored results. State belonging to
+// other conversations sharing the Tools is kept, and a saved batch replaces
+// the pending results for the same assistant message.
+func (f *Tools) Restore(ctx context.Context, data json.RawMessage) error {
+	var ts toolsSnapshot
+	if err := json.Unmarshal(data, &ts); err != nil {
+		return err
+	}
+
+	msgIDs := make([]string, 0, len(ts.Results))
+	for msgID := range ts.Results {
+		msgIDs = append(msgIDs, msgID)
+	}
+	sort.Strings(msgIDs)
+
+	f.mu.Lock()
+	for _, msgID := range msgIDs {
+		f.dropBatch(msgID)
+		for callID, m := range ts.Results[msgID] {
+			if m != nil {
+				f.save(msgID, callID, callResult{msg: m})
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	if f.stored != nil {
+		for _, r := range ts.Stored {
+			f.stored.put(r.ID, r.Content)
+		}
+	}
+
+	return nil
+}
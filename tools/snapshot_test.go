@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolsSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	newTools := func() *Tools {
+		ts := New(WithParallelCalls(0))
+		ts.Add("echo", "Echo the arguments", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+			calls.Add(1)
+			return args, nil
+		})
+		return ts
+	}
+
+	assistantMsg := agent.NewContentMessage(agent.RoleAssistant, "")
+	assistantMsg.ToolCalls = []agent.ToolCall{
+		{ID: "call_1", Name: "echo", Arguments: `{"n": 1}`},
+		{ID: "call_2", Name: "echo", Arguments: `{"n": 2}`},
+	}
+	final := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "All done!"), nil
+	}
+
+	ts := newTools()
+	first, err := ts.CompletionFunc(final)(ctx, []*agent.Message{assistantMsg}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "call_1", first.ToolCallID)
+
+	data, err := ts.Snapshot(ctx)
+	require.NoError(t, err)
+
+	// A restored set returns the pending result without calling again.
+	restored := newTools()
+	require.NoError(t, restored.Restore(ctx, data))
+
+	second, err := restored.CompletionFunc(final)(ctx, []*agent.Message{assistantMsg, first}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "call_2", second.ToolCallID)
+	content, _ := second.Content(ctx)
+	assert.Equal(t, `{"n": 2}`, content)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestToolsSnapshotStored(t *testing.T) {
+	ctx := context.Background()
+
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 10, Mode: ResultStore}))
+	ts.stored.put("call_1", "a long result")
+
+	data, err := ts.Snapshot(ctx)
+	require.NoError(t, err)
+
+	restored := New(WithResultLimit(ResultLimit{MaxBytes: 10, Mode: ResultStore}))
+	require.NoError(t, restored.Restore(ctx, data))
+
+	content, ok := restored.stored.get("call_1")
+	require.True(t, ok)
+	assert.Equal(t, "a long result", content)
+}
+
+func TestToolsRestoreShared(t *testing.T) {
+	ctx := context.Background()
+
+	ts := New(WithResultLimit(ResultLimit{MaxBytes: 10, Mode: ResultStore}))
+	ts.stored.put("result_a", "first conversation")
+	ts.mu.Lock()
+	ts.save("msg_a", "call_1", callResult{msg: agent.NewContentMessage(agent.RoleTool, "a")})
+	ts.mu.Unlock()
+
+	data, err := ts.Snapshot(ctx)
+	require.NoError(t, err)
+
+	// Another conversation sharing the set moves on before the first is
+	// restored.
+	ts.stored.put("result_b", "second conversation")
+	ts.mu.Lock()
+	ts.save("msg_b", "call_1", callResult{msg: agent.NewContentMessage(agent.RoleTool, "b")})
+	ts.mu.Unlock()
+
+	require.NoError(t, ts.Restore(ctx, data))
+
+	for _, id := range []string{"result_a", "result_b"} {
+		_, ok := ts.stored.get(id)
+		assert.True(t, ok, id)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	assert.Contains(t, ts.results, "msg_a")
+	assert.Contains(t, ts.results, "msg_b")
+	assert.ElementsMatch(t, []string{"msg_a", "msg_b"}, ts.batchOrder)
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// tool is a registered tool function along with its execution settings.
+type tool struct {
+	fn     ResultTool
+	schema map[string]any
+
+	timeout time.Duration
+	retries int
+	sem     chan struct{}
+
+	// internal tools, such as read_tool_result, only read state the Tools
+	// already holds, so they aren't sent to the Approver.
+	internal bool
+}
+
+type ToolOption func(t *tool)
+
+// WithTimeout limits how long a single invocation of the tool may run.
+//
+// A tool that ignores context cancellation keeps running in the background
+// after the timeout, but the step no longer waits for it.
+func WithTimeout(d time.Duration) ToolOption {
+	return func(t *tool) {
+		t.timeout = d
+	}
+}
+
+// WithMaxConcurrency limits how many invocations of the tool may run at once.
+// Additional calls wait for a slot. An invocation that outlives its timeout
+// keeps its slot until it actually returns.
+func WithMaxConcurrency(n int) ToolOption {
+	return func(t *tool) {
+		if n > 0 {
+			t.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithRetries retries a failed invocation up to n times. Retries happen
+// before the Tools error handler is consulted. Calls whose arguments can't be
+// decoded aren't retried.
+func WithRetries(n int) ToolOption {
+	return func(t *tool) {
+		t.retries = n
+	}
+}
+
+// internalTool marks a tool registered by the Tools itself.
+func internalTool() ToolOption {
+	return func(t *tool) {
+		t.internal = true
+	}
+}
+
+// TimeoutError is the error produced when a tool exceeds its timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("tool timed out after %s", e.Timeout)
+}
+
+func newTool(fn ResultTool, parameters any, opts ...ToolOption) *tool {
+	// Parameters that can't be represented as JSON aren't validated.
+	schema, err := normalizeSchema(parameters)
+	if err != nil {
+		schema = nil
+	}
+
+	t := &tool{
+		fn:     fn,
+		schema: schema,
+	}
+
+	for _, o := range opts {
+		o(t)
+	}
+
+	return t
+}
+
+func (t *tool) run(ctx context.Context, arguments string) (*Result, error) {
+	var err error
+	for attempt := 0; attempt <= t.retries; attempt++ {
+		var res *Result
+		res, err = t.runOnce(ctx, arguments)
+		if err == nil {
+			return res, nil
+		}
+
+		var ae *argumentsError
+		if ctx.Err() != nil || errors.As(err, &ae) {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// acquire waits for a concurrency slot, returning the function that frees
+// it.
+func (t *tool) acquire(ctx context.Context) (func(), error) {
+	if t.sem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case t.sem <- struct{}{}:
+		return func() { <-t.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *tool) runOnce(ctx context.Context, arguments string) (*Result, error) {
+	release, err := t.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.timeout <= 0 {
+		defer release()
+		return invoke(ctx, t.fn, arguments)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	type result struct {
+		res *Result
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		// The slot is held until the tool returns, even if the timeout
+		// fires first.
+		defer release()
+		res, err := invoke(tctx, t.fn, arguments)
+		done <- result{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded) {
+			return nil, &TimeoutError{Timeout: t.timeout}
+		}
+		return r.res, r.err
+	case <-tctx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &TimeoutError{Timeout: t.timeout}
+	}
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolTimeout(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	ts.Add("hang", "Never returns", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		// Ignores the context entirely.
+		<-block
+		return "done", nil
+	}, WithTimeout(10*time.Millisecond))
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "hang", Arguments: "{}"})
+	require.NoError(t, err)
+	assert.True(t, msg.HasTag(ErrorTag))
+
+	var te ToolError
+	content, _ := msg.Content(ctx)
+	require.NoError(t, json.Unmarshal([]byte(content), &te))
+	assert.Equal(t, ToolError{Error: "tool timed out after 10ms", Type: "timeout", Tool: "hang"}, te)
+}
+
+func TestToolTimeoutContext(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	ts.Add("slow", "Respects the context", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, WithTimeout(10*time.Millisecond))
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "slow", Arguments: "{}"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Contains(t, content, `"type":"timeout"`)
+}
+
+func TestToolRetries(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	attempts := 0
+	ts.Add("flaky", "Fails twice", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", errors.New("try again")
+		}
+		return "ok", nil
+	}, WithRetries(2))
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "flaky", Arguments: "{}"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, "ok", content)
+
+	attempts = -10
+	_, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: "flaky", Arguments: "{}"})
+	assert.EqualError(t, err, "try again")
+	assert.Equal(t, -7, attempts)
+}
+
+func TestToolRetriesBadArguments(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithValidation(false))
+
+	attempts := 0
+	typed := TypedTool(func(ctx context.Context, args readArgs) (string, error) {
+		return args.Path, nil
+	})
+	ts.Add("read", "Read a file", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		attempts++
+		return typed(ctx, args)
+	}, WithRetries(3))
+
+	// Arguments that can't be decoded fail the same way every time.
+	_, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "read", Arguments: `{"path": 1}`})
+	assert.ErrorContains(t, err, "could not parse arguments as JSON")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestToolMaxConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	var running, maxRunning atomic.Int32
+	ts.Add("work", "Do some work", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return "ok", nil
+	}, WithMaxConcurrency(1))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "work", Arguments: "{}"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxRunning.Load())
+}
+
+func TestToolMaxConcurrencyTimeout(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	var running, maxRunning atomic.Int32
+	ts.Add("hang", "Ignores the context", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		return "done", nil
+	}, WithTimeout(5*time.Millisecond), WithRetries(2), WithMaxConcurrency(1))
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "hang", Arguments: "{}"})
+	require.NoError(t, err)
+	assert.True(t, msg.HasTag(ErrorTag))
+
+	// Retries wait for the timed out invocation to return.
+	assert.Equal(t, int32(1), maxRunning.Load())
+}
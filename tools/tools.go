@@ -2,107 +2,596 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/rhettg/agent"
 )
 
 type Tools struct {
-	fns  map[string]agent.Tool
-	defs []agent.ToolDef
+	defsMu   sync.RWMutex
+	tools    map[string]*tool
+	defs     []agent.ToolDef
+	disabled map[string]bool
+	selector Selector
+
+	parallel     bool
+	limit        int
+	errorHandler ErrorHandler
+	validate     bool
+	approver     Approver
+	resultLimit  *ResultLimit
+	stored       *resultStore
+	storeErr     error
+	observers    []agent.Observer
+
+	// results holds the unreturned results of parallel calls, by the ID of
+	// the assistant message that made them.
+	mu         sync.Mutex
+	results    map[string]batch
+	batchOrder []string
 }
 
-func (f *Tools) Add(name, description string, parameters any, fn agent.Tool) {
-	def := agent.ToolDef{
-		Name:        name,
-		Description: description,
-		Parameters:  parameters,
+type Option func(f *Tools)
+
+// WithParallelCalls executes all pending tool calls from an assistant message
+// concurrently, running at most limit at a time (no limit if limit <= 0).
+//
+// Each Step still returns a single tool message. The remaining results are
+// held until subsequent steps so they are added in the original call order.
+func WithParallelCalls(limit int) Option {
+	return func(f *Tools) {
+		f.parallel = true
+		f.limit = limit
 	}
+}
+
+// ErrToolExists is returned when adding a tool with a name that is already
+// registered.
+var ErrToolExists = errors.New("tool already exists")
+
+// ErrToolNotFound is returned when referring to a tool that isn't registered.
+var ErrToolNotFound = errors.New("tool not found")
 
-	f.defs = append(f.defs, def)
-	f.fns[name] = fn
+// Selector chooses which of the tool definitions to offer to the model for
+// the next completion.
+type Selector func(ctx context.Context, msgs []*agent.Message, defs []agent.ToolDef) []agent.ToolDef
+
+// WithSelector filters the tools offered to the model on each step, allowing
+// different tools to be available in different phases of a conversation.
+//
+// The selector is also applied when pending calls are executed, so a call to
+// a tool it hides, whether hallucinated or left over from earlier in the
+// conversation, is answered as if the tool didn't exist. Calls made directly
+// with Call aren't filtered.
+func WithSelector(s Selector) Option {
+	return func(f *Tools) {
+		f.selector = s
+	}
 }
 
-func (f *Tools) AddTools(fs *Tools) {
-	for _, def := range fs.defs {
-		f.Add(def.Name, def.Description, def.Parameters, f.fns[def.Name])
+func (f *Tools) Add(name, description string, parameters any, fn agent.Tool, opts ...ToolOption) error {
+	return f.AddResultTool(name, description, parameters, textTool(fn), opts...)
+}
+
+// AddTools adds all the tools from fs. If any of the names are already
+// registered, no tools are added.
+func (f *Tools) AddTools(fs *Tools) error {
+	return f.AddToolsWithPrefix("", fs)
+}
+
+// AddToolsWithPrefix adds all the tools from fs with their names prefixed,
+// such as "fs_" to turn "read_file" into "fs_read_file". If any of the
+// resulting names are already registered, no tools are added.
+func (f *Tools) AddToolsWithPrefix(prefix string, fs *Tools) error {
+	fs.defsMu.RLock()
+	defs := make([]agent.ToolDef, len(fs.defs))
+	copy(defs, fs.defs)
+	tools := make(map[string]*tool, len(fs.tools))
+	for name, t := range fs.tools {
+		tools[name] = t
+	}
+	disabled := make(map[string]bool, len(fs.disabled))
+	for name := range fs.disabled {
+		disabled[name] = true
+	}
+	fs.defsMu.RUnlock()
+
+	f.defsMu.Lock()
+	defer f.defsMu.Unlock()
+
+	for _, def := range defs {
+		if _, ok := f.tools[prefix+def.Name]; ok {
+			return fmt.Errorf("%w: %s", ErrToolExists, prefix+def.Name)
+		}
+	}
+
+	for _, def := range defs {
+		name := def.Name
+		def.Name = prefix + name
+
+		f.defs = append(f.defs, def)
+		f.tools[def.Name] = tools[name]
+		if disabled[name] {
+			f.disabled[def.Name] = true
+		}
 	}
+
+	return nil
+}
+
+// Remove unregisters a tool. It reports whether the tool existed.
+func (f *Tools) Remove(name string) bool {
+	f.defsMu.Lock()
+	defer f.defsMu.Unlock()
+
+	if _, ok := f.tools[name]; !ok {
+		return false
+	}
+
+	delete(f.tools, name)
+	delete(f.disabled, name)
+
+	defs := make([]agent.ToolDef, 0, len(f.defs))
+	for _, def := range f.defs {
+		if def.Name != name {
+			defs = append(defs, def)
+		}
+	}
+	f.defs = defs
+
+	return true
+}
+
+// Disable stops offering a tool to the model and rejects calls to it until
+// it is enabled again.
+func (f *Tools) Disable(name string) error {
+	return f.setDisabled(name, true)
+}
+
+// Enable makes a disabled tool available again.
+func (f *Tools) Enable(name string) error {
+	return f.setDisabled(name, false)
 }
 
+func (f *Tools) setDisabled(name string, disabled bool) error {
+	f.defsMu.Lock()
+	defer f.defsMu.Unlock()
+
+	if _, ok := f.tools[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+
+	if disabled {
+		f.disabled[name] = true
+	} else {
+		delete(f.disabled, name)
+	}
+
+	return nil
+}
+
+// Has reports whether a tool is registered, whether or not it is enabled.
+func (f *Tools) Has(name string) bool {
+	f.defsMu.RLock()
+	defer f.defsMu.RUnlock()
+
+	_, ok := f.tools[name]
+	return ok
+}
+
+// Defs returns the definitions of the enabled tools.
+func (f *Tools) Defs() []agent.ToolDef {
+	f.defsMu.RLock()
+	defer f.defsMu.RUnlock()
+
+	defs := make([]agent.ToolDef, 0, len(f.defs))
+	for _, def := range f.defs {
+		if !f.disabled[def.Name] {
+			defs = append(defs, def)
+		}
+	}
+
+	return defs
+}
+
+func (f *Tools) lookup(name string) (*tool, bool) {
+	f.defsMu.RLock()
+	defer f.defsMu.RUnlock()
+
+	if f.disabled[name] {
+		return nil, false
+	}
+
+	t, ok := f.tools[name]
+	return t, ok
+}
+
+// Call executes a tool call directly, outside of a completion chain. The
+// usual validation, approval and error handling apply.
+func (f *Tools) Call(ctx context.Context, toolCall agent.ToolCall) (*agent.Message, error) {
+	return f.call(ctx, &toolCall)
+}
+
+// call executes a tool call, reporting its start and finish to observers.
 func (f *Tools) call(ctx context.Context, toolCall *agent.ToolCall) (*agent.Message, error) {
-	fn, ok := f.fns[toolCall.Name]
+	tc := *toolCall
+	start := time.Now()
+	f.emit(ctx, agent.Event{Type: agent.EventToolCallStarted, Time: start, ToolCall: &tc})
+
+	m, err := f.execute(ctx, toolCall)
+
+	f.emit(ctx, agent.Event{
+		Type:     agent.EventToolCallFinished,
+		Duration: time.Since(start),
+		Message:  m,
+		ToolCall: &tc,
+		Err:      err,
+	})
+
+	return m, err
+}
+
+func (f *Tools) execute(ctx context.Context, toolCall *agent.ToolCall) (*agent.Message, error) {
+	t, ok := f.lookup(toolCall.Name)
+	if ok {
+		ok = f.selected(ctx, toolCall.Name)
+	}
 	if !ok {
 		m := agent.NewContentMessage(agent.RoleTool, fmt.Sprintf("tool not found: %s", toolCall.Name))
 		m.ToolCallID = toolCall.ID
 		return m, nil
 	}
 
-	resp, err := fn(ctx, toolCall.Arguments)
-	if err != nil {
-		return nil, err
+	if f.validate {
+		if m := validateArguments(t.schema, toolCall); m != nil {
+			return m, nil
+		}
+	}
+
+	edited := false
+	if f.approver != nil && !t.internal {
+		approved, m, err := f.approve(ctx, toolCall)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			return m, nil
+		}
+
+		if approved != toolCall {
+			edited = true
+			toolCall = approved
+
+			if f.validate {
+				if m := validateArguments(t.schema, toolCall); m != nil {
+					return m, nil
+				}
+			}
+		}
 	}
 
-	m := agent.NewContentMessage(agent.RoleTool, resp)
-	m.ToolCallID = toolCall.ID
+	for attempt := 1; ; attempt++ {
+		var content string
+		var images []agent.Image
+		res, err := t.run(ctx, toolCall.Arguments)
+		if err == nil {
+			content, images, err = res.render()
+		}
+		if err == nil {
+			content, truncated, err := f.limitResult(ctx, toolCall, content)
+			if err != nil {
+				return nil, err
+			}
 
-	return m, nil
+			m := agent.NewContentMessage(agent.RoleTool, content)
+			m.ToolCallID = toolCall.ID
+			for _, img := range images {
+				m.AddPart(agent.ImagePartFrom(img))
+			}
+			if truncated {
+				m.Tag(TruncatedTag)
+			}
+			if edited {
+				m.SetAttr(EditedArgumentsAttr, toolCall.Arguments)
+			}
+
+			return m, nil
+		}
+
+		// A canceled step is never something the model can fix.
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		if f.errorHandler == nil {
+			// Without a handler, timeouts are still reported to the model
+			// rather than failing the step.
+			var te *TimeoutError
+			if errors.As(err, &te) {
+				return newErrorMessage(toolCall, err), nil
+			}
+			return nil, err
+		}
+
+		switch f.errorHandler(ctx, *toolCall, attempt, err) {
+		case ErrorRetry:
+			continue
+		case ErrorSurface:
+			return newErrorMessage(toolCall, err), nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+type selectedKey struct{}
+
+// selection is the set of tools a selector chose for a step.
+type selection struct {
+	tools *Tools
+	names map[string]bool
+}
+
+// withSelected records the tools chosen by the selector, which are the only
+// ones that may be executed.
+func (f *Tools) withSelected(ctx context.Context, defs []agent.ToolDef) context.Context {
+	names := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		names[def.Name] = true
+	}
+	return context.WithValue(ctx, selectedKey{}, selection{tools: f, names: names})
+}
+
+// selected reports whether a tool may be executed. The selection only
+// applies to the Tools that made it, not to others used by a tool.
+func (f *Tools) selected(ctx context.Context, name string) bool {
+	s, ok := ctx.Value(selectedKey{}).(selection)
+	return !ok || s.tools != f || s.names[name]
+}
+
+// maxBatches is how many parallel batches with unreturned results are kept.
+// Batches from abandoned continuations, such as after a rewind, are dropped
+// once it's exceeded.
+const maxBatches = 32
+
+// callResult is the outcome of one call in a parallel batch.
+type callResult struct {
+	msg *agent.Message
+	err error
+}
+
+// batch holds the results of the parallel calls from one assistant message
+// that haven't been returned yet, by tool call ID.
+type batch map[string]callResult
+
+// callParallel executes the pending tool calls of an assistant message
+// concurrently and returns the result for the first one. Other results,
+// including errors, are saved for later steps.
+func (f *Tools) callParallel(ctx context.Context, call *agent.Message, pending []agent.ToolCall) (*agent.Message, error) {
+	id := call.ID()
+
+	f.mu.Lock()
+	if r, ok := f.results[id][pending[0].ID]; ok {
+		f.take(id, pending[0].ID)
+		f.mu.Unlock()
+		return r.msg, r.err
+	}
+
+	// Calls that already completed in an earlier batch are not run again.
+	calls := make([]agent.ToolCall, 0, len(pending))
+	for _, tc := range pending {
+		if _, ok := f.results[id][tc.ID]; !ok {
+			calls = append(calls, tc)
+		}
+	}
+	f.mu.Unlock()
+
+	var sem chan struct{}
+	if f.limit > 0 {
+		sem = make(chan struct{}, f.limit)
+	}
+
+	msgs := make([]*agent.Message, len(calls))
+	errs := make([]error, len(calls))
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					errs[i] = ctx.Err()
+					return
+				}
+			}
+
+			msgs[i], errs[i] = f.call(ctx, &calls[i])
+		}(i)
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Calls cut short by cancellation are run again rather than failing a
+	// later step.
+	for i := 1; i < len(calls); i++ {
+		if errs[i] != nil && ctx.Err() != nil {
+			continue
+		}
+		f.save(id, calls[i].ID, callResult{msgs[i], errs[i]})
+	}
+
+	return msgs[0], errs[0]
+}
+
+// save records a result for a later step. f.mu must be held.
+func (f *Tools) save(msgID, callID string, r callResult) {
+	b, ok := f.results[msgID]
+	if !ok {
+		b = make(batch)
+		f.results[msgID] = b
+		f.batchOrder = append(f.batchOrder, msgID)
+	}
+	b[callID] = r
+
+	for len(f.batchOrder) > maxBatches {
+		f.dropBatch(f.batchOrder[0])
+	}
+}
+
+// take removes a result once it has been returned, dropping the batch when it
+// is finished. f.mu must be held.
+func (f *Tools) take(msgID, callID string) {
+	b := f.results[msgID]
+	delete(b, callID)
+	if len(b) == 0 {
+		f.dropBatch(msgID)
+	}
+}
+
+// dropBatch discards a batch. f.mu must be held.
+func (f *Tools) dropBatch(msgID string) {
+	delete(f.results, msgID)
+	for i, id := range f.batchOrder {
+		if id == msgID {
+			f.batchOrder = append(f.batchOrder[:i:i], f.batchOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// forgetBatches drops the batches of assistant messages in msgs other than
+// current. Their calls have been answered some other way, or the history was
+// edited, so the saved results will never be used.
+func (f *Tools) forgetBatches(msgs []*agent.Message, current *agent.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.results) == 0 {
+		return
+	}
+
+	for _, m := range msgs {
+		if m.Role == agent.RoleAssistant && m != current {
+			if _, ok := f.results[m.ID()]; ok {
+				f.dropBatch(m.ID())
+			}
+		}
+	}
 }
 
 func (f *Tools) CompletionFunc(nextStep agent.CompletionFunc) agent.CompletionFunc {
 	return func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
-		// Find the first unexecuted tool call
-		if toolCall := f.findUnexecutedToolCall(msgs); toolCall != nil {
-			return f.call(ctx, toolCall)
+		// Find the unexecuted tool calls
+		call, pending := f.findUnexecutedToolCalls(msgs)
+		if f.parallel {
+			f.forgetBatches(msgs, call)
+		}
+		if len(pending) > 0 {
+			if f.selector != nil {
+				ctx = f.withSelected(ctx, f.selector(ctx, msgs, f.Defs()))
+			}
+
+			if f.parallel {
+				return f.callParallel(ctx, call, pending)
+			}
+			return f.call(ctx, &pending[0])
+		}
+
+		defs := f.Defs()
+		if f.selector != nil {
+			defs = f.selector(ctx, msgs, defs)
 		}
 
-		nfns := make([]agent.ToolDef, 0, len(tdfs)+len(f.defs))
+		nfns := make([]agent.ToolDef, 0, len(tdfs)+len(defs))
 		nfns = append(nfns, tdfs...)
-		nfns = append(nfns, f.defs...)
+		nfns = append(nfns, defs...)
 
 		return nextStep(ctx, msgs, nfns)
 	}
 }
 
-// findUnexecutedToolCall searches through the conversation to find tool calls that haven't been executed yet
-func (f *Tools) findUnexecutedToolCall(msgs []*agent.Message) *agent.ToolCall {
+// findUnexecutedToolCalls searches through the conversation to find tool calls
+// that haven't been executed yet. Only calls from the first assistant message
+// with pending calls are returned, in their original order, along with that
+// message.
+func (f *Tools) findUnexecutedToolCalls(msgs []*agent.Message) (*agent.Message, []agent.ToolCall) {
 	// Track executed tool calls by their IDs
 	executedCallIDs := make(map[string]bool)
-	
+
 	// First pass: collect all executed tool call IDs
 	for _, msg := range msgs {
 		if msg.Role == agent.RoleTool && msg.ToolCallID != "" {
 			executedCallIDs[msg.ToolCallID] = true
 		}
 	}
-	
+
 	// Second pass: find unexecuted tool calls
 	for _, msg := range msgs {
 		if msg.Role == agent.RoleAssistant && msg.HasToolCalls() {
+			var pending []agent.ToolCall
 			for _, toolCall := range msg.ToolCalls {
 				if !executedCallIDs[toolCall.ID] {
-					return &toolCall
+					pending = append(pending, toolCall)
 				}
 			}
+			if len(pending) > 0 {
+				return msg, pending
+			}
 		}
 	}
-	
-	return nil
+
+	return nil, nil
 }
 
-func New() *Tools {
-	return &Tools{
-		fns:  make(map[string]agent.Tool),
-		defs: make([]agent.ToolDef, 0),
+func New(opts ...Option) *Tools {
+	f := &Tools{
+		tools:    make(map[string]*tool),
+		defs:     make([]agent.ToolDef, 0),
+		disabled: make(map[string]bool),
+		results:  make(map[string]batch),
+		validate: true,
 	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	return f
 }
 
 func NewToolsFromTools(fs *Tools) *Tools {
 	nfs := New()
-	nfs.AddTools(fs)
+	nfs.parallel = fs.parallel
+	nfs.limit = fs.limit
+	nfs.errorHandler = fs.errorHandler
+	nfs.validate = fs.validate
+	nfs.approver = fs.approver
+	nfs.resultLimit = fs.resultLimit
+	nfs.stored = fs.stored
+	nfs.selector = fs.selector
+	nfs.observers = fs.observers
+
+	// A new set has no tools that could collide.
+	_ = nfs.AddTools(fs)
 
 	return nfs
 }
 
+// WithTools adds the tools to an agent, including their pending and stored
+// results in the agent's snapshots.
 func WithTools(f *Tools) agent.Option {
-	return agent.WithMiddleware(f.CompletionFunc)
+	return func(a *agent.Agent) {
+		agent.WithMiddleware(f.CompletionFunc)(a)
+		agent.WithSnapshotter("tools", f)(a)
+	}
 }
@@ -2,7 +2,12 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/rhettg/agent"
 	"github.com/stretchr/testify/assert"
@@ -78,7 +83,7 @@ func TestMultipleToolCalls(t *testing.T) {
 	ts.Add("add", "Add two numbers", EmptyParameters, func(ctx context.Context, args string) (string, error) {
 		return "Sum: 7", nil
 	})
-	
+
 	ts.Add("multiply", "Multiply two numbers", EmptyParameters, func(ctx context.Context, args string) (string, error) {
 		return "Product: 12", nil
 	})
@@ -116,7 +121,7 @@ func TestMultipleToolCalls(t *testing.T) {
 	content2, _ := result2.Content(ctx)
 	assert.Equal(t, "Product: 12", content2)
 
-	// Add the second tool result to messages  
+	// Add the second tool result to messages
 	msgs = append(msgs, result2)
 
 	// Third call should pass to next step since all tools are executed
@@ -132,3 +137,200 @@ func TestMultipleToolCalls(t *testing.T) {
 	content3, _ := result3.Content(ctx)
 	assert.Equal(t, "All done!", content3)
 }
+
+func TestParallelToolCalls(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithParallelCalls(0))
+
+	var started sync.WaitGroup
+	started.Add(3)
+
+	var calls atomic.Int32
+	ts.Add("wait", "Wait for all calls to start", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		calls.Add(1)
+		started.Done()
+		// Only returns if all calls are running at the same time.
+		started.Wait()
+		return args, nil
+	})
+
+	assistantMsg := agent.NewContentMessage(agent.RoleAssistant, "")
+	assistantMsg.ToolCalls = []agent.ToolCall{
+		{ID: "call_1", Name: "wait", Arguments: `{"n": 1}`},
+		{ID: "call_2", Name: "wait", Arguments: `{"n": 2}`},
+		{ID: "call_3", Name: "wait", Arguments: `{"n": 3}`},
+	}
+
+	msgs := []*agent.Message{assistantMsg}
+
+	completionFunc := ts.CompletionFunc(func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "All done!"), nil
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		result, err := completionFunc(ctx, msgs, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "call_"+id, result.ToolCallID)
+		content, _ := result.Content(ctx)
+		assert.Equal(t, `{"n": `+id+`}`, content)
+		msgs = append(msgs, result)
+	}
+
+	assert.Equal(t, int32(3), calls.Load())
+
+	// The finished batch isn't kept.
+	assert.Empty(t, ts.results)
+
+	result, err := completionFunc(ctx, msgs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, agent.RoleAssistant, result.Role)
+}
+
+func TestParallelToolCallsLimit(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithParallelCalls(2))
+
+	var running, maxRunning atomic.Int32
+	ts.Add("work", "Do some work", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "ok", nil
+	})
+
+	assistantMsg := agent.NewContentMessage(agent.RoleAssistant, "")
+	for i := 0; i < 6; i++ {
+		assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, agent.ToolCall{ID: fmt.Sprintf("call_%d", i), Name: "work"})
+	}
+
+	result, err := ts.CompletionFunc(nil)(ctx, []*agent.Message{assistantMsg}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "call_0", result.ToolCallID)
+	assert.Equal(t, int32(2), maxRunning.Load())
+}
+
+func TestParallelToolCallsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := New(WithParallelCalls(1))
+
+	ts.Add("block", "Block until canceled", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		cancel()
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	assistantMsg := agent.NewContentMessage(agent.RoleAssistant, "")
+	assistantMsg.ToolCalls = []agent.ToolCall{
+		{ID: "call_1", Name: "block"},
+		{ID: "call_2", Name: "block"},
+	}
+
+	_, err := ts.CompletionFunc(nil)(ctx, []*agent.Message{assistantMsg}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParallelToolCallsShared(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithParallelCalls(0))
+	ts.Add("echo", "Echo the arguments", EmptyParameters, echo)
+
+	// Two conversations share the tools and use the same call IDs.
+	newCall := func(arg string) *agent.Message {
+		m := agent.NewContentMessage(agent.RoleAssistant, "")
+		m.ToolCalls = []agent.ToolCall{
+			{ID: "call_1", Name: "echo", Arguments: `{"a": "` + arg + `1"}`},
+			{ID: "call_2", Name: "echo", Arguments: `{"a": "` + arg + `2"}`},
+		}
+		return m
+	}
+	a, b := newCall("a"), newCall("b")
+
+	cf := ts.CompletionFunc(nil)
+	ra, err := cf(ctx, []*agent.Message{a}, nil)
+	require.NoError(t, err)
+	rb, err := cf(ctx, []*agent.Message{b}, nil)
+	require.NoError(t, err)
+
+	ra, err = cf(ctx, []*agent.Message{a, ra}, nil)
+	require.NoError(t, err)
+	content, _ := ra.Content(ctx)
+	assert.Equal(t, `{"a": "a2"}`, content)
+
+	rb, err = cf(ctx, []*agent.Message{b, rb}, nil)
+	require.NoError(t, err)
+	content, _ = rb.Content(ctx)
+	assert.Equal(t, `{"a": "b2"}`, content)
+}
+
+func TestParallelToolCallsErrors(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithParallelCalls(0))
+
+	var calls atomic.Int32
+	ts.Add("fail", "Always fails", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		calls.Add(1)
+		return "", errors.New("broken")
+	})
+	ts.Add("echo", "Echo the arguments", EmptyParameters, echo)
+
+	assistantMsg := agent.NewContentMessage(agent.RoleAssistant, "")
+	assistantMsg.ToolCalls = []agent.ToolCall{
+		{ID: "call_1", Name: "echo", Arguments: "{}"},
+		{ID: "call_2", Name: "fail", Arguments: "{}"},
+	}
+
+	cf := ts.CompletionFunc(nil)
+	first, err := cf(ctx, []*agent.Message{assistantMsg}, nil)
+	require.NoError(t, err)
+
+	// The saved error is returned rather than running the call again.
+	_, err = cf(ctx, []*agent.Message{assistantMsg, first}, nil)
+	assert.EqualError(t, err, "broken")
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Empty(t, ts.results)
+}
+
+func TestParallelToolCallsAbandoned(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithParallelCalls(0))
+	ts.Add("echo", "Echo the arguments", EmptyParameters, echo)
+
+	assistantMsg := agent.NewContentMessage(agent.RoleAssistant, "")
+	assistantMsg.ToolCalls = []agent.ToolCall{
+		{ID: "call_1", Name: "echo", Arguments: "{}"},
+		{ID: "call_2", Name: "echo", Arguments: "{}"},
+	}
+
+	final := func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		return agent.NewContentMessage(agent.RoleAssistant, "done"), nil
+	}
+	cf := ts.CompletionFunc(final)
+
+	_, err := cf(ctx, []*agent.Message{assistantMsg}, nil)
+	require.NoError(t, err)
+	require.Len(t, ts.results, 1)
+
+	// The calls were answered another way, so the saved result is dropped.
+	r1 := agent.NewContentMessage(agent.RoleTool, "edited")
+	r1.ToolCallID = "call_1"
+	r2 := agent.NewContentMessage(agent.RoleTool, "edited")
+	r2.ToolCallID = "call_2"
+	_, err = cf(ctx, []*agent.Message{assistantMsg, r1, r2}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ts.results)
+
+	// Batches from continuations that are never resumed are capped.
+	for i := 0; i < maxBatches+5; i++ {
+		m := agent.NewContentMessage(agent.RoleAssistant, "")
+		m.ToolCalls = assistantMsg.ToolCalls
+		_, err := cf(ctx, []*agent.Message{m}, nil)
+		require.NoError(t, err)
+	}
+	assert.Len(t, ts.results, maxBatches)
+}
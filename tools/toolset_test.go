@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func defNames(defs []agent.ToolDef) []string {
+	names := make([]string, 0, len(defs))
+	for _, d := range defs {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestAddCollision(t *testing.T) {
+	ts := New()
+	require.NoError(t, ts.Add("hello", "Say hello", EmptyParameters, hello))
+
+	err := ts.Add("hello", "Say hello again", EmptyParameters, hello)
+	assert.ErrorIs(t, err, ErrToolExists)
+	assert.Len(t, ts.Defs(), 1)
+
+	other := New()
+	require.NoError(t, other.Add("goodbye", "Say goodbye", EmptyParameters, hello))
+	require.NoError(t, other.Add("hello", "Say hello", EmptyParameters, hello))
+
+	// Nothing is added if any name collides.
+	err = ts.AddTools(other)
+	assert.ErrorIs(t, err, ErrToolExists)
+	assert.Equal(t, []string{"hello"}, defNames(ts.Defs()))
+}
+
+func TestAddToolsWithPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	fs := New()
+	require.NoError(t, fs.Add("read", "Read a file", EmptyParameters, echo))
+	require.NoError(t, fs.Add("write", "Write a file", EmptyParameters, echo))
+	require.NoError(t, fs.Disable("write"))
+
+	ts := New()
+	require.NoError(t, ts.Add("read", "Read a web page", EmptyParameters, hello))
+	require.NoError(t, ts.AddToolsWithPrefix("fs_", fs))
+
+	assert.Equal(t, []string{"read", "fs_read"}, defNames(ts.Defs()))
+	assert.True(t, ts.Has("fs_write"))
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "fs_read", Arguments: `{"path": "a"}`})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, `{"path": "a"}`, content)
+}
+
+func TestRemoveAndDisable(t *testing.T) {
+	ctx := context.Background()
+
+	ts := New()
+	require.NoError(t, ts.Add("hello", "Say hello", EmptyParameters, hello))
+	require.NoError(t, ts.Add("echo", "Echo arguments", EmptyParameters, echo))
+
+	require.NoError(t, ts.Disable("hello"))
+	assert.Equal(t, []string{"echo"}, defNames(ts.Defs()))
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "hello", Arguments: "{}"})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, "tool not found: hello", content)
+
+	require.NoError(t, ts.Enable("hello"))
+	assert.Equal(t, []string{"hello", "echo"}, defNames(ts.Defs()))
+
+	assert.True(t, ts.Remove("hello"))
+	assert.False(t, ts.Remove("hello"))
+	assert.False(t, ts.Has("hello"))
+	assert.Equal(t, []string{"echo"}, defNames(ts.Defs()))
+
+	assert.ErrorIs(t, ts.Disable("hello"), ErrToolNotFound)
+
+	// The name can be reused once removed.
+	assert.NoError(t, ts.Add("hello", "Say hello", EmptyParameters, hello))
+}
+
+func TestSelector(t *testing.T) {
+	ctx := context.Background()
+
+	// Only offer the write tool once the user has asked for changes.
+	selector := func(ctx context.Context, msgs []*agent.Message, defs []agent.ToolDef) []agent.ToolDef {
+		for _, m := range msgs {
+			if m.HasTag("allow_writes") {
+				return defs
+			}
+		}
+
+		selected := make([]agent.ToolDef, 0)
+		for _, d := range defs {
+			if d.Name != "write" {
+				selected = append(selected, d)
+			}
+		}
+		return selected
+	}
+
+	ts := New(WithSelector(selector))
+	require.NoError(t, ts.Add("read", "Read a file", EmptyParameters, echo))
+	require.NoError(t, ts.Add("write", "Write a file", EmptyParameters, echo))
+
+	var offered []string
+	completionFunc := ts.CompletionFunc(func(ctx context.Context, msgs []*agent.Message, tdfs []agent.ToolDef) (*agent.Message, error) {
+		offered = defNames(tdfs)
+		return agent.NewContentMessage(agent.RoleAssistant, "ok"), nil
+	})
+
+	msgs := []*agent.Message{agent.NewContentMessage(agent.RoleUser, "what is in the file?")}
+	_, err := completionFunc(ctx, msgs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, offered)
+
+	m := agent.NewContentMessage(agent.RoleUser, "fix it")
+	m.Tag("allow_writes")
+	msgs = append(msgs, m)
+
+	_, err = completionFunc(ctx, msgs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, offered)
+}
+
+func TestSelectorExecution(t *testing.T) {
+	ctx := context.Background()
+
+	selector := func(ctx context.Context, msgs []*agent.Message, defs []agent.ToolDef) []agent.ToolDef {
+		selected := make([]agent.ToolDef, 0)
+		for _, d := range defs {
+			if d.Name != "write" {
+				selected = append(selected, d)
+			}
+		}
+		return selected
+	}
+
+	var wrote bool
+	ts := New(WithSelector(selector))
+	require.NoError(t, ts.Add("read", "Read a file", EmptyParameters, echo))
+	require.NoError(t, ts.Add("write", "Write a file", EmptyParameters, func(ctx context.Context, args string) (string, error) {
+		wrote = true
+		return "written", nil
+	}))
+
+	// The model calls a tool it wasn't offered.
+	call := agent.NewContentMessage(agent.RoleAssistant, "")
+	call.ToolCalls = []agent.ToolCall{{ID: "call_1", Name: "write", Arguments: "{}"}}
+
+	m, err := ts.CompletionFunc(nil)(ctx, []*agent.Message{call}, nil)
+	require.NoError(t, err)
+	content, _ := m.Content(ctx)
+	assert.Equal(t, "tool not found: write", content)
+	assert.False(t, wrote)
+
+	// Calling the tool directly isn't filtered.
+	_, err = ts.Call(ctx, call.ToolCalls[0])
+	require.NoError(t, err)
+	assert.True(t, wrote)
+}
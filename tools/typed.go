@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rhettg/agent"
+)
+
+// AddFunc registers a tool backed by a typed function.
+//
+// The parameters schema is generated from In (see Schema). Arguments from the
+// model are decoded into In before fn is called. String results are returned
+// as-is, a *Result is used directly and anything else is encoded as JSON.
+func AddFunc[In, Out any](ts *Tools, name, description string, fn func(context.Context, In) (Out, error), opts ...ToolOption) error {
+	var in In
+	params, err := Schema(in)
+	if err != nil {
+		return fmt.Errorf("failed to generate schema for %s: %w", name, err)
+	}
+
+	return ts.AddResultTool(name, description, params, typedResultTool(fn), opts...)
+}
+
+// TypedTool adapts a typed function into an agent.Tool, handling decoding of
+// arguments and encoding of results.
+func TypedTool[In, Out any](fn func(context.Context, In) (Out, error)) agent.Tool {
+	return func(ctx context.Context, arguments string) (string, error) {
+		in, err := decodeArguments[In](arguments)
+		if err != nil {
+			return "", err
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			return "", err
+		}
+
+		return encodeResult(out)
+	}
+}
+
+func typedResultTool[In, Out any](fn func(context.Context, In) (Out, error)) ResultTool {
+	return func(ctx context.Context, arguments string) (*Result, error) {
+		in, err := decodeArguments[In](arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		if r, ok := any(out).(*Result); ok {
+			return r, nil
+		}
+
+		s, err := encodeResult(out)
+		if err != nil {
+			return nil, err
+		}
+		return NewResult(Text(s)), nil
+	}
+}
+
+// argumentsError reports arguments that couldn't be decoded, which calling
+// the tool again won't fix.
+type argumentsError struct {
+	err error
+}
+
+func (e *argumentsError) Error() string {
+	return fmt.Sprintf("could not parse arguments as JSON: %v", e.err)
+}
+
+func (e *argumentsError) Unwrap() error {
+	return e.err
+}
+
+func decodeArguments[In any](arguments string) (In, error) {
+	var in In
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &in); err != nil {
+			return in, &argumentsError{err}
+		}
+	}
+	return in, nil
+}
+
+func encodeResult(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"math/big"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readArgs struct {
+	Path     string   `json:"path" description:"file to read"`
+	MaxLines *int     `json:"max_lines,omitempty" minimum:"1" maximum:"1000"`
+	Mode     string   `json:"mode,omitempty" enum:"text,binary"`
+	Tags     []string `json:"tags,omitempty"`
+	Ignored  string   `json:"-"`
+}
+
+func TestSchema(t *testing.T) {
+	s, err := Schema(readArgs{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "object", s["type"])
+	assert.Equal(t, false, s["additionalProperties"])
+	assert.Equal(t, []string{"path"}, s["required"])
+
+	props := s["properties"].(map[string]any)
+	assert.Len(t, props, 4)
+	assert.Equal(t, map[string]any{"type": "string", "description": "file to read"}, props["path"])
+	assert.Equal(t, map[string]any{"type": "integer", "minimum": 1.0, "maximum": 1000.0}, props["max_lines"])
+	assert.Equal(t, map[string]any{"type": "string", "enum": []any{"text", "binary"}}, props["mode"])
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, props["tags"])
+
+	_, err = Schema("not a struct")
+	assert.Error(t, err)
+
+	_, err = Schema(struct{ C chan int }{})
+	assert.Error(t, err)
+}
+
+type treeNode struct {
+	Name     string     `json:"name"`
+	Children []treeNode `json:"children"`
+}
+
+type linked struct {
+	Next *linked `json:"next,omitempty"`
+}
+
+func TestSchemaEncodedTypes(t *testing.T) {
+	s, err := Schema(struct {
+		Data []byte    `json:"data"`
+		At   time.Time `json:"at"`
+		Raw  [2]byte   `json:"raw"`
+	}{})
+	require.NoError(t, err)
+
+	props := s["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "contentEncoding": "base64"}, props["data"])
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, props["at"])
+	assert.Equal(t, "array", props["raw"].(map[string]any)["type"])
+}
+
+func TestSchemaMarshalers(t *testing.T) {
+	type args struct {
+		Addr  netip.Addr    `json:"addr"`
+		Addrs []*netip.Addr `json:"addrs,omitempty"`
+		N     *big.Int      `json:"n"`
+	}
+
+	s, err := Schema(args{})
+	require.NoError(t, err)
+
+	props := s["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"}, props["addr"])
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, props["addrs"])
+	// big.Int marshals itself to JSON as a number, so it isn't constrained.
+	assert.Equal(t, map[string]any{}, props["n"])
+
+	ts := New()
+	err = AddFunc(ts, "ping", "Ping an address", func(ctx context.Context, a args) (string, error) {
+		return a.Addr.String() + " " + a.N.String(), nil
+	})
+	require.NoError(t, err)
+
+	msg, err := ts.Call(context.Background(), agent.ToolCall{ID: "1", Name: "ping", Arguments: `{"addr":"1.2.3.4","n":12345678901234567890}`})
+	require.NoError(t, err)
+	c, err := msg.Content(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4 12345678901234567890", c)
+}
+
+func TestSchemaRecursive(t *testing.T) {
+	_, err := Schema(treeNode{})
+	assert.ErrorContains(t, err, "recursive type")
+
+	_, err = Schema(linked{})
+	assert.ErrorContains(t, err, "recursive type")
+
+	// A struct used twice side by side isn't recursive.
+	type pair struct {
+		A readArgs `json:"a"`
+		B readArgs `json:"b"`
+	}
+	_, err = Schema(pair{})
+	assert.NoError(t, err)
+}
+
+func TestAddFunc(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	type result struct {
+		Lines int `json:"lines"`
+	}
+
+	err := AddFunc(ts, "read", "Read a file", func(ctx context.Context, args readArgs) (result, error) {
+		assert.Equal(t, "a.txt", args.Path)
+		require.NotNil(t, args.MaxLines)
+		return result{Lines: *args.MaxLines}, nil
+	})
+	require.NoError(t, err)
+
+	err = AddFunc(ts, "greet", "Say hello", func(ctx context.Context, args struct{}) (string, error) {
+		return "hello", nil
+	})
+	require.NoError(t, err)
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "read", Arguments: `{"path": "a.txt", "max_lines": 3}`})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, `{"lines":3}`, content)
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "2", Name: "greet", Arguments: ""})
+	require.NoError(t, err)
+	content, _ = msg.Content(ctx)
+	assert.Equal(t, "hello", content)
+
+	msg, err = ts.call(ctx, &agent.ToolCall{ID: "3", Name: "read", Arguments: `{"path": `})
+	require.NoError(t, err)
+	assert.True(t, msg.HasTag(ErrorTag))
+}
@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rhettg/agent"
+)
+
+// WithValidation enables or disables validation of tool call arguments
+// against the tool's parameters schema. Validation is enabled by default.
+func WithValidation(enabled bool) Option {
+	return func(f *Tools) {
+		f.validate = enabled
+	}
+}
+
+// normalizeSchema converts a parameters definition into generic JSON values
+// so it can be inspected regardless of how it was declared.
+func normalizeSchema(parameters any) (map[string]any, error) {
+	if parameters == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var s map[string]any
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// validateArguments checks the arguments of a tool call against the schema.
+// If they are invalid, a tool message describing the problems is returned so
+// the model can correct its call.
+func validateArguments(schema map[string]any, toolCall *agent.ToolCall) *agent.Message {
+	if schema == nil {
+		return nil
+	}
+
+	arguments := toolCall.Arguments
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	var problems []string
+
+	var v any
+	if err := json.Unmarshal([]byte(arguments), &v); err != nil {
+		problems = append(problems, fmt.Sprintf("arguments are not valid JSON: %v", err))
+	} else {
+		problems = validateValue(schema, v, "")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	content := strings.Builder{}
+	content.WriteString(fmt.Sprintf("invalid arguments for tool %s:\n", toolCall.Name))
+	for _, p := range problems {
+		content.WriteString("- ")
+		content.WriteString(p)
+		content.WriteString("\n")
+	}
+
+	m := agent.NewContentMessage(agent.RoleTool, strings.TrimSuffix(content.String(), "\n"))
+	m.ToolCallID = toolCall.ID
+	m.Tag(ErrorTag)
+
+	return m
+}
+
+func validateValue(schema map[string]any, v any, path string) []string {
+	where := path
+	if where == "" {
+		where = "arguments"
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, v) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", where, typeList(t), jsonType(v))}
+	}
+
+	var problems []string
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: must be one of %s", where, formatValues(enum)))
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, v) {
+		problems = append(problems, fmt.Sprintf("%s: must be %s", where, formatValues([]any{c})))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		problems = append(problems, validateObject(schema, val, path)...)
+	case []any:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(val)) < min {
+			problems = append(problems, fmt.Sprintf("%s: must have at least %v items", where, min))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(val)) > max {
+			problems = append(problems, fmt.Sprintf("%s: must have at most %v items", where, max))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range val {
+				problems = append(problems, validateValue(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		n := float64(utf8.RuneCountInString(val))
+		if min, ok := schema["minLength"].(float64); ok && n < min {
+			problems = append(problems, fmt.Sprintf("%s: must be at least %v characters", where, min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && n > max {
+			problems = append(problems, fmt.Sprintf("%s: must be at most %v characters", where, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(val) {
+				problems = append(problems, fmt.Sprintf("%s: must match pattern %q", where, pattern))
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && val < min {
+			problems = append(problems, fmt.Sprintf("%s: must be >= %v", where, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && val > max {
+			problems = append(problems, fmt.Sprintf("%s: must be <= %v", where, max))
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && val <= min {
+			problems = append(problems, fmt.Sprintf("%s: must be > %v", where, min))
+		}
+		if max, ok := schema["exclusiveMaximum"].(float64); ok && val >= max {
+			problems = append(problems, fmt.Sprintf("%s: must be < %v", where, max))
+		}
+	}
+
+	return problems
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string) []string {
+	var problems []string
+
+	prefix := ""
+	if path != "" {
+		prefix = path + "."
+	}
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s%s: missing required property", prefix, name))
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if ps, ok := properties[k].(map[string]any); ok {
+			problems = append(problems, validateValue(ps, obj[k], prefix+k)...)
+			continue
+		}
+
+		switch ap := schema["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				problems = append(problems, fmt.Sprintf("%s%s: unknown property", prefix, k))
+			}
+		case map[string]any:
+			problems = append(problems, validateValue(ap, obj[k], prefix+k)...)
+		}
+	}
+
+	return problems
+}
+
+func matchesType(t any, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		return matchesTypeName(tt, v)
+	case []any:
+		for _, name := range tt {
+			if s, ok := name.(string); ok && matchesTypeName(s, v) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesTypeName(name string, v any) bool {
+	switch name {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return jsonType(v) == name
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func typeList(t any) string {
+	if names, ok := t.([]any); ok {
+		parts := make([]string, 0, len(names))
+		for _, n := range names {
+			parts = append(parts, fmt.Sprint(n))
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func formatValues(values []any) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rhettg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var searchParameters = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"query": map[string]any{
+			"type":      "string",
+			"minLength": 1,
+		},
+		"limit": map[string]any{
+			"type":    "integer",
+			"minimum": 1,
+			"maximum": 50,
+		},
+		"sort": map[string]any{
+			"type": "string",
+			"enum": []string{"relevance", "date"},
+		},
+		"tags": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "string"},
+		},
+	},
+	"required":             []string{"query"},
+	"additionalProperties": false,
+}
+
+func TestValidateArguments(t *testing.T) {
+	ctx := context.Background()
+	ts := New()
+
+	called := 0
+	ts.Add("search", "Search documents", searchParameters, func(ctx context.Context, args string) (string, error) {
+		called++
+		return "found it", nil
+	})
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "search", Arguments: `{"query": "cats", "limit": 5, "sort": "date", "tags": ["a"]}`})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, "found it", content)
+	assert.Equal(t, 1, called)
+
+	tests := []struct {
+		arguments string
+		expected  string
+	}{
+		{`{}`, "- query: missing required property"},
+		{`{"query": ""}`, "- query: must be at least 1 characters"},
+		{`{"query": 7}`, "- query: expected string, got number"},
+		{`{"query": "cats", "limit": 2.5}`, "- limit: expected integer, got number"},
+		{`{"query": "cats", "limit": 100}`, "- limit: must be <= 50"},
+		{`{"query": "cats", "sort": "random"}`, `- sort: must be one of ["relevance","date"]`},
+		{`{"query": "cats", "tags": [1]}`, "- tags[0]: expected string, got number"},
+		{`{"query": "cats", "color": "red"}`, "- color: unknown property"},
+		{`[]`, "- arguments: expected object, got array"},
+		{`{"query": `, "- arguments are not valid JSON"},
+	}
+
+	for _, tt := range tests {
+		msg, err := ts.call(ctx, &agent.ToolCall{ID: "2", Name: "search", Arguments: tt.arguments})
+		require.NoError(t, err)
+		assert.Equal(t, "2", msg.ToolCallID)
+		assert.True(t, msg.HasTag(ErrorTag))
+
+		content, _ := msg.Content(ctx)
+		assert.Contains(t, content, "invalid arguments for tool search:\n")
+		assert.Contains(t, content, tt.expected, tt.arguments)
+	}
+
+	assert.Equal(t, 1, called)
+}
+
+func TestValidationDisabled(t *testing.T) {
+	ctx := context.Background()
+	ts := New(WithValidation(false))
+
+	ts.Add("search", "Search documents", searchParameters, func(ctx context.Context, args string) (string, error) {
+		return "found it", nil
+	})
+
+	msg, err := ts.call(ctx, &agent.ToolCall{ID: "1", Name: "search", Arguments: `{}`})
+	require.NoError(t, err)
+	content, _ := msg.Content(ctx)
+	assert.Equal(t, "found it", content)
+}